@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// BillingEntry is one row of the takeout's Bills.html: an international call
+// charge, a credit purchase, or a refund.
+type BillingEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Description string    `json:"description"`
+	Number      string    `json:"number,omitempty"`
+	Duration    string    `json:"duration,omitempty"`
+	// Amount is the value exactly as rendered, e.g. "-$0.02".
+	Amount string `json:"amount"`
+	// AmountCents is Amount in minor currency units. Charges are negative.
+	AmountCents int64  `json:"amount_cents"`
+	Currency    string `json:"currency,omitempty"`
+	SourceFile  string `json:"source_file"`
+	Account     string `json:"account,omitempty"`
+}
+
+// AccountPhone is a number attached to the Google Voice account, from the
+// takeout's Phones.vcf. The Voice number itself is labelled "Google Voice";
+// the others are linked forwarding numbers.
+type AccountPhone struct {
+	PhoneNumber string   `json:"phone_number"`
+	Label       string   `json:"label,omitempty"`
+	Types       []string `json:"types,omitempty"`
+	SourceFile  string   `json:"source_file"`
+	Account     string   `json:"account,omitempty"`
+}
+
+// BillingWriter is implemented by output writers that can store Bills.html
+// entries. Writers without it skip billing data.
+type BillingWriter interface {
+	WriteBillingEntries([]BillingEntry) error
+}
+
+// AccountWriter is implemented by output writers that can store the account
+// phone numbers from Phones.vcf.
+type AccountWriter interface {
+	WriteAccountPhones([]AccountPhone) error
+}
+
+// findTakeoutFile looks for a file that lives beside the Calls directory in
+// a Voice takeout. The parser is normally run from inside Calls, so the
+// parent directory is checked after the current one.
+func findTakeoutFile(name string) (string, bool) {
+	for _, dir := range []string{".", ".."} {
+		p := filepath.Join(dir, name)
+		if st, err := os.Stat(p); err == nil && !st.IsDir() {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// detectAccount returns the Google Voice number from Phones.vcf, which
+// identifies the account a takeout came from. It returns "" if there is no
+// Phones.vcf.
+func detectAccount() (string, error) {
+	path, ok := findTakeoutFile("Phones.vcf")
+	if !ok {
+		return "", nil
+	}
+	phones, err := parsePhonesFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range phones {
+		if p.Label == "Google Voice" {
+			return p.PhoneNumber, nil
+		}
+	}
+	if len(phones) > 0 {
+		return phones[0].PhoneNumber, nil
+	}
+	return "", nil
+}
+
+// importAccountData writes Bills.html, Phones.vcf, and the Greetings folder
+// to w if they exist and w supports them. Everything is attributed to
+// account. Bill dates that carry no time zone are read in loc, or UTC if it
+// is nil.
+func importAccountData(lgr *slog.Logger, w OutputWriter, account string, loc *time.Location) error {
+	if path, ok := findTakeoutFile("Bills.html"); ok {
+		bw, supported := w.(BillingWriter)
+		if !supported {
+			lgr.Info("output format does not support billing data, skipping", "file", path)
+		} else {
+			entries, err := parseBillsFile(lgr, path, loc)
+			if err != nil {
+				return err
+			}
+			for i := range entries {
+				entries[i].Account = account
+			}
+			if err := bw.WriteBillingEntries(entries); err != nil {
+				return fmt.Errorf("write billing entries: %w", err)
+			}
+		}
+	}
+
+	if path, ok := findTakeoutFile("Phones.vcf"); ok {
+		aw, supported := w.(AccountWriter)
+		if !supported {
+			lgr.Info("output format does not support account data, skipping", "file", path)
+		} else {
+			phones, err := parsePhonesFile(path)
+			if err != nil {
+				return err
+			}
+			for i := range phones {
+				phones[i].Account = account
+			}
+			if err := aw.WriteAccountPhones(phones); err != nil {
+				return fmt.Errorf("write account phones: %w", err)
+			}
+		}
+	}
+
+	if dir, ok := findTakeoutDir("Greetings"); ok {
+		gw, supported := w.(GreetingWriter)
+		if !supported {
+			lgr.Info("output format does not support greetings, skipping", "dir", dir)
+		} else {
+			greetings, err := findGreetings(dir)
+			if err != nil {
+				return fmt.Errorf("read greetings: %w", err)
+			}
+			for i := range greetings {
+				greetings[i].Account = account
+			}
+			if err := gw.WriteGreetings(greetings); err != nil {
+				return fmt.Errorf("write greetings: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseBillsFile(lgr *slog.Logger, path string, loc *time.Location) ([]BillingEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := parseBills(lgr, f, loc)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i := range entries {
+		entries[i].SourceFile = path
+	}
+	return entries, nil
+}
+
+// billColumn identifies what a Bills.html table column holds, based on its
+// header text.
+type billColumn int
+
+const (
+	billColumnUnknown billColumn = iota
+	billColumnDate
+	billColumnDescription
+	billColumnNumber
+	billColumnDuration
+	billColumnAmount
+)
+
+func classifyBillHeader(h string) billColumn {
+	h = strings.ToLower(h)
+	switch {
+	case strings.Contains(h, "date"), strings.Contains(h, "time"):
+		return billColumnDate
+	case strings.Contains(h, "amount"), strings.Contains(h, "charge"),
+		strings.Contains(h, "cost"), strings.Contains(h, "price"), strings.Contains(h, "total"):
+		return billColumnAmount
+	case strings.Contains(h, "duration"), strings.Contains(h, "minutes"):
+		return billColumnDuration
+	case strings.Contains(h, "number"), strings.Contains(h, "destination"), h == "to":
+		return billColumnNumber
+	case strings.Contains(h, "description"), strings.Contains(h, "type"), strings.Contains(h, "item"):
+		return billColumnDescription
+	}
+	return billColumnUnknown
+}
+
+// billCell is the text of a table cell plus the machine readable values
+// Google embeds in attributes (abbr titles and tel: links).
+type billCell struct {
+	text  string
+	title string
+	tel   string
+}
+
+// parseBills reads the transaction tables from Bills.html. Google has
+// changed the column layout over time, so columns are identified by their
+// header text rather than position. Rows before a header row are ignored.
+// Dates rendered without a time zone are read in loc, or UTC if it is nil,
+// so stored timestamps don't depend on the machine running the import.
+func parseBills(lgr *slog.Logger, r io.Reader, loc *time.Location) ([]BillingEntry, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	z := html.NewTokenizer(r)
+
+	var (
+		entries  []BillingEntry
+		columns  []billColumn
+		row      []billCell
+		isHeader bool
+		inCell   bool
+		cell     billCell
+		text     strings.Builder
+	)
+
+	finishRow := func() {
+		if isHeader && len(row) > 0 {
+			columns = columns[:0]
+			for _, c := range row {
+				columns = append(columns, classifyBillHeader(c.text))
+			}
+			return
+		}
+		if len(columns) == 0 || len(row) == 0 {
+			return
+		}
+		entry, ok := billingEntryFromRow(lgr, columns, row, loc)
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			return entries, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := atom.Lookup(name)
+			var title, href []byte
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				switch string(key) {
+				case "title":
+					title = val
+				case "href":
+					href = val
+				}
+			}
+			switch tag {
+			case atom.Table:
+				columns = nil
+			case atom.Tr:
+				row = row[:0]
+				isHeader = true
+			case atom.Th, atom.Td:
+				// A row is a header only if every cell is a th.
+				if tag == atom.Td {
+					isHeader = false
+				}
+				inCell = true
+				cell = billCell{}
+				text.Reset()
+			case atom.Abbr, atom.Time:
+				if inCell && title != nil {
+					cell.title = string(title)
+				}
+			case atom.A:
+				if inCell && bytes.HasPrefix(href, []byte("tel:")) {
+					cell.tel = string(href[len("tel:"):])
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Th, atom.Td:
+				if inCell {
+					cell.text = strings.Join(strings.Fields(text.String()), " ")
+					row = append(row, cell)
+					inCell = false
+				}
+			case atom.Tr:
+				finishRow()
+				row = row[:0]
+			}
+		case html.TextToken:
+			if inCell {
+				text.Write(z.Text())
+			}
+		}
+	}
+}
+
+func billingEntryFromRow(lgr *slog.Logger, columns []billColumn, row []billCell, loc *time.Location) (BillingEntry, bool) {
+	var (
+		entry   BillingEntry
+		dateErr error
+	)
+	for i, c := range row {
+		if i >= len(columns) {
+			break
+		}
+		switch columns[i] {
+		case billColumnDate:
+			entry.Timestamp, dateErr = parseBillTime(c, loc)
+		case billColumnDescription:
+			entry.Description = c.text
+		case billColumnNumber:
+			entry.Number = c.text
+			if c.tel != "" {
+				entry.Number = c.tel
+			}
+		case billColumnDuration:
+			entry.Duration = strings.Trim(c.text, "()")
+		case billColumnAmount:
+			entry.Amount = c.text
+			cents, currency, err := parseAmount(c.text)
+			if err != nil {
+				lgr.Warn("unparsable billing amount", "value", c.text, "err", err)
+			}
+			entry.AmountCents = cents
+			entry.Currency = currency
+		}
+	}
+
+	// Skip totals and spacer rows that carry no transaction data.
+	if entry.Timestamp.IsZero() && entry.Amount == "" {
+		return entry, false
+	}
+	if dateErr != nil {
+		lgr.Warn("unparsable billing date", "err", dateErr)
+	}
+	return entry, true
+}
+
+var billTimeLayouts = []string{
+	time.RFC3339,
+	"Jan 2, 2006, 3:04:05 PM",
+	"Jan 2, 2006, 3:04 PM",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"1/2/06",
+	"1/2/2006",
+}
+
+func parseBillTime(c billCell, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, c.title); err == nil {
+		return t, nil
+	}
+	for _, layout := range billTimeLayouts {
+		if t, err := time.ParseInLocation(layout, c.text, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date format %q", c.text)
+}
+
+// parseAmount converts a rendered amount such as "-$1.25", "($1.25)",
+// "USD 10.00", or "€0,10" into minor units and a currency symbol or code.
+func parseAmount(s string) (int64, string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, "", nil
+	}
+
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+
+	var number, currency strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',':
+			number.WriteRune(r)
+		case r == '-', r == '−':
+			negative = true
+		case r == ' ', r == '+':
+		default:
+			currency.WriteRune(r)
+		}
+	}
+
+	// The last '.' or ',' is the decimal separator if it is followed by one
+	// or two digits; otherwise separators are grouping ("1,000").
+	digits := number.String()
+	whole, frac := digits, ""
+	if i := strings.LastIndexAny(digits, ".,"); i >= 0 && len(digits)-i-1 <= 2 {
+		whole, frac = digits[:i], digits[i+1:]
+	}
+	whole = strings.NewReplacer(",", "", ".", "").Replace(whole)
+	if whole == "" {
+		whole = "0"
+	}
+	frac = (frac + "00")[:2]
+	cents, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		cents = -cents
+	}
+	return cents, strings.TrimSpace(currency.String()), nil
+}
+
+func parsePhonesFile(path string) ([]AccountPhone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	phones, err := parsePhones(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i := range phones {
+		phones[i].SourceFile = path
+	}
+	return phones, nil
+}
+
+// parsePhones extracts the account's numbers from Phones.vcf.
+func parsePhones(r io.Reader) ([]AccountPhone, error) {
+	cards, err := parseVCards(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var phones []AccountPhone
+	for _, card := range cards {
+		for _, tel := range card.All("TEL") {
+			number := strings.TrimPrefix(strings.TrimSpace(tel.Value), "tel:")
+			if number == "" {
+				continue
+			}
+			phones = append(phones, AccountPhone{
+				PhoneNumber: number,
+				Label:       card.GroupValue(tel, "X-ABLABEL"),
+				Types:       tel.Params["TYPE"],
+			})
+		}
+	}
+	return phones, nil
+}
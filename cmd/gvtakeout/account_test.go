@@ -0,0 +1,312 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBills(t *testing.T) {
+	f, err := os.Open("testdata/Bills.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Dates without a zone are read in the -tz location, whatever the
+	// machine's own zone.
+	eastern := time.FixedZone("Eastern", -5*60*60)
+	entries, err := parseBills(slog.New(slog.NewTextHandler(io.Discard, nil)), f, eastern)
+	if err != nil {
+		t.Fatalf("Failed to parse bills: %v", err)
+	}
+
+	expected := []BillingEntry{
+		{
+			Timestamp:   time.Date(2019, 3, 2, 10, 15, 0, 0, time.FixedZone("Pacific Time", -8*60*60)),
+			Description: "Credit purchase",
+			Amount:      "$10.00",
+			AmountCents: 1000,
+			Currency:    "$",
+		},
+		{
+			Timestamp:   time.Date(2019, 3, 4, 18, 40, 12, 0, time.FixedZone("Pacific Time", -8*60*60)),
+			Description: "International call",
+			Number:      "+442071234567",
+			Duration:    "00:12:31",
+			Amount:      "-$1.30",
+			AmountCents: -130,
+			Currency:    "$",
+		},
+		{
+			Timestamp:   time.Date(2019, 3, 9, 0, 0, 0, 0, eastern),
+			Description: "Refund",
+			Amount:      "$0.25",
+			AmountCents: 25,
+			Currency:    "$",
+		},
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(expected), len(entries), entries)
+	}
+	for i, e := range expected {
+		got := entries[i]
+		if !got.Timestamp.Equal(e.Timestamp) {
+			t.Errorf("Entry %d: Expected timestamp %v, got %v", i, e.Timestamp, got.Timestamp)
+		}
+		if got.Description != e.Description {
+			t.Errorf("Entry %d: Expected description %s, got %s", i, e.Description, got.Description)
+		}
+		if got.Number != e.Number {
+			t.Errorf("Entry %d: Expected number %s, got %s", i, e.Number, got.Number)
+		}
+		if got.Duration != e.Duration {
+			t.Errorf("Entry %d: Expected duration %s, got %s", i, e.Duration, got.Duration)
+		}
+		if got.Amount != e.Amount || got.AmountCents != e.AmountCents || got.Currency != e.Currency {
+			t.Errorf("Entry %d: Expected amount %s (%d %s), got %s (%d %s)", i, e.Amount, e.AmountCents, e.Currency, got.Amount, got.AmountCents, got.Currency)
+		}
+	}
+}
+
+func TestParseBillsDefaultsToUTC(t *testing.T) {
+	f, err := os.Open("testdata/Bills.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	entries, err := parseBills(slog.New(slog.NewTextHandler(io.Discard, nil)), f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2019, 3, 9, 0, 0, 0, 0, time.UTC)
+	if len(entries) != 3 || !entries[2].Timestamp.Equal(want) {
+		t.Errorf("Expected the refund at %v, got %+v", want, entries)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in       string
+		cents    int64
+		currency string
+	}{
+		{"$1.25", 125, "$"},
+		{"-$0.02", -2, "$"},
+		{"($3.5)", -350, "$"},
+		{"USD 1,000", 100000, "USD"},
+		{"€0,10", 10, "€"},
+		{"", 0, ""},
+	}
+	for _, tc := range tests {
+		cents, currency, err := parseAmount(tc.in)
+		if err != nil {
+			t.Errorf("parseAmount(%q): %v", tc.in, err)
+			continue
+		}
+		if cents != tc.cents || currency != tc.currency {
+			t.Errorf("parseAmount(%q) = %d %q, expected %d %q", tc.in, cents, currency, tc.cents, tc.currency)
+		}
+	}
+}
+
+func TestParsePhones(t *testing.T) {
+	f, err := os.Open("testdata/Phones.vcf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	phones, err := parsePhones(f)
+	if err != nil {
+		t.Fatalf("Failed to parse phones: %v", err)
+	}
+
+	expected := []AccountPhone{
+		{PhoneNumber: "+2222", Label: "Google Voice"},
+		{PhoneNumber: "+15555550100", Types: []string{"CELL"}},
+		{PhoneNumber: "+15555550199", Types: []string{"WORK", "VOICE"}},
+	}
+	if len(phones) != len(expected) {
+		t.Fatalf("Expected %d phones, got %d: %+v", len(expected), len(phones), phones)
+	}
+	for i, p := range expected {
+		got := phones[i]
+		if got.PhoneNumber != p.PhoneNumber || got.Label != p.Label || len(got.Types) != len(p.Types) {
+			t.Errorf("Phone %d: Expected %+v, got %+v", i, p, got)
+		}
+	}
+}
+
+func TestWriteBillingEntriesTwice(t *testing.T) {
+	f, err := os.Open("testdata/Bills.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := parseBills(slog.New(slog.NewTextHandler(io.Discard, nil)), f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A row whose date couldn't be read is skipped too.
+	entries = append(entries, BillingEntry{Description: "Credit", Amount: "$1.00", AmountCents: 100, Currency: "USD"})
+
+	dbName := filepath.Join(t.TempDir(), "bills.db")
+	for i := 0; i < 2; i++ {
+		w := &sqliteWriter{dbName: dbName}
+		if err := w.Begin(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteBillingEntries(entries); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := openReadOnly(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM billing_entry").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(entries) {
+		t.Errorf("Expected %d billing entries after importing twice, got %d", len(entries), n)
+	}
+}
+
+func TestDetectAccount(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	account, err := detectAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account != "+2222" {
+		t.Errorf("Expected the Google Voice number +2222, got %q", account)
+	}
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	account, err = detectAccount()
+	if err != nil || account != "" {
+		t.Errorf("Expected no account without Phones.vcf, got %q, %v", account, err)
+	}
+}
+
+func TestTwoAccounts(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "accounts.db")
+	w := &sqliteWriter{dbName: dbName}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, account := range []string{"+1111", "+2222"} {
+		for _, conv := range parseTestdata(t, "sms.html") {
+			conv.Account = account
+			if err := w.Write(conv); err != nil {
+				t.Fatal(err)
+			}
+		}
+		greeting := Greeting{
+			Name:       "Greeting " + account[1:],
+			RecordedAt: time.Date(2016, 2, 14, 20, 31, 7, 0, time.UTC),
+			Path:       "testdata/Dwigt Rortugal - Recorded - 2012-03-04T18_11_12Z.mp3",
+			Account:    account,
+		}
+		if err := w.WriteGreetings([]Greeting{greeting}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	db, err = openReadOnly(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, _, err = loadAssets("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	displayLoc = time.UTC
+	defer func() {
+		db.Close()
+		db, displayLoc, templates = nil, nil, nil
+	}()
+
+	// Each account has a Me of its own.
+	me, err := queryStrings(db, `SELECT DISTINCT contact.account FROM contact
+		JOIN participant ON participant.contact_id = contact.id
+		WHERE participant.is_self ORDER BY contact.account`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(me) != 2 || me[0] != "+1111" || me[1] != "+2222" {
+		t.Errorf("Expected a Me contact per account, got %v", me)
+	}
+
+	var threads []string
+	for _, account := range []string{"+1111", "+2222"} {
+		groups, err := getGroups(account)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != 1 {
+			t.Fatalf("Expected one group for %s, got %+v", account, groups)
+		}
+		threads = append(threads, groups[0].Key)
+
+		greetings, err := getGreetings(account)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(greetings) != 1 || greetings[0].Name != "Greeting "+account[1:] {
+			t.Errorf("Expected only the greeting of %s, got %+v", account, greetings)
+		}
+	}
+	if threads[0] == threads[1] {
+		t.Errorf("Expected each account's thread apart, got %v", threads)
+	}
+	if groups, err := getGroups(""); err != nil || len(groups) != 2 {
+		t.Errorf("Expected both accounts' groups without a filter, got %d, %v", len(groups), err)
+	}
+
+	// A group page asked for under another account is empty.
+	msgs, err := getMessagesForGroup(threads[0], "+2222")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("Expected no messages of +1111's thread under +2222, got %d", len(msgs))
+	}
+
+	req := httptest.NewRequest("GET", "/greetings?account=%2B1111", nil)
+	rec := httptest.NewRecorder()
+	greetingsHandler(rec, req)
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "Greeting 1111") || strings.Contains(body, "Greeting 2222") {
+		t.Errorf("Expected only +1111's greeting, got %d:\n%s", rec.Code, body)
+	}
+}
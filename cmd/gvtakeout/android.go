@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SMS Backup & Restore, the usual way to export an Android phone's texts and
+// call log, writes an <smses> file of <sms> and <mms> elements and a <calls>
+// file of <call> elements. Timestamps are epoch milliseconds and numbers are
+// as the phone stored them, with or without a country code.
+
+// androidOrigin is the Conversation.Origin of everything imported from an
+// Android backup.
+const androidOrigin = "android"
+
+type androidSMS struct {
+	Address     string `xml:"address,attr"`
+	Date        int64  `xml:"date,attr"`
+	Type        int    `xml:"type,attr"`
+	Body        string `xml:"body,attr"`
+	ContactName string `xml:"contact_name,attr"`
+}
+
+type androidMMS struct {
+	Address     string `xml:"address,attr"`
+	Date        int64  `xml:"date,attr"`
+	MsgBox      int    `xml:"msg_box,attr"`
+	ContactName string `xml:"contact_name,attr"`
+	Parts       []struct {
+		ContentType string `xml:"ct,attr"`
+		Text        string `xml:"text,attr"`
+		Name        string `xml:"name,attr"`
+		Location    string `xml:"cl,attr"`
+	} `xml:"parts>part"`
+	Addrs []struct {
+		Address string `xml:"address,attr"`
+		Type    int    `xml:"type,attr"`
+	} `xml:"addrs>addr"`
+}
+
+type androidCall struct {
+	Number      string `xml:"number,attr"`
+	Duration    int    `xml:"duration,attr"`
+	Date        int64  `xml:"date,attr"`
+	Type        int    `xml:"type,attr"`
+	ContactName string `xml:"contact_name,attr"`
+}
+
+// Values of the type attribute of <sms> and <call>, and of the msg_box
+// attribute of <mms>.
+const (
+	androidReceived = 1
+	androidSent     = 2
+	androidDraft    = 3
+
+	// mmsFrom is the type of the sender in an <mms>'s <addrs>.
+	mmsFrom = 137
+)
+
+// androidCallTypes maps the type attribute of <call> to a call type.
+var androidCallTypes = map[int]string{
+	1: "received_call",
+	2: "placed_call",
+	3: "missed_call",
+	4: "voicemail",
+}
+
+func runImportAndroid(args []string) error {
+	return runExternalImport("import-android", "<backup.xml>...", args, func(name, account string, known knownContacts, loc *time.Location) ([]Conversation, error) {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return parseAndroidBackup(f, filepath.Base(name), account, known, loc)
+	})
+}
+
+// parseAndroidBackup reads an SMS Backup & Restore file. Texts are grouped
+// into one chat per set of other participants; each call is a conversation
+// of its own.
+func parseAndroidBackup(r io.Reader, sourceFile, account string, known knownContacts, loc *time.Location) ([]Conversation, error) {
+	var (
+		threads = make(map[string]*Conversation)
+		keys    []string
+		calls   []Conversation
+	)
+	thread := func(others []Participant) *Conversation {
+		numbers := make([]string, len(others))
+		for i, p := range others {
+			numbers[i] = p.Number
+		}
+		sort.Strings(numbers)
+		key := strings.Join(numbers, "\x00")
+		if c, ok := threads[key]; ok {
+			return c
+		}
+		c := &Conversation{Type: "chat", SourceFile: sourceFile, Account: account, Origin: androidOrigin}
+		c.Participants.add(known.me(participantFromAddress))
+		for _, p := range others {
+			c.Participants.add(p)
+		}
+		threads[key] = c
+		keys = append(keys, key)
+		return c
+	}
+
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "sms":
+			var s androidSMS
+			if err := dec.DecodeElement(&s, &start); err != nil {
+				return nil, err
+			}
+			if s.Type == androidDraft {
+				continue
+			}
+			other := known.contact(s.Address, s.ContactName, participantFromAddress)
+			c := thread([]Participant{other})
+			m := Message{Timestamp: time.UnixMilli(s.Date).In(loc), Content: nullAttr(s.Body)}
+			if s.Type == androidReceived {
+				m.Sender, m.SenderNumber = other.Name, other.Number
+			} else {
+				m.Sender, m.SenderNumber = known.self.Name, known.self.Number
+			}
+			c.Messages = append(c.Messages, m)
+
+		case "mms":
+			var s androidMMS
+			if err := dec.DecodeElement(&s, &start); err != nil {
+				return nil, err
+			}
+			if s.MsgBox == androidDraft {
+				continue
+			}
+			numbers := strings.Split(s.Address, "~")
+			names := strings.Split(s.ContactName, ", ")
+			var others []Participant
+			for i, n := range numbers {
+				name := ""
+				if len(names) == len(numbers) {
+					name = names[i]
+				}
+				if p := known.contact(n, name, participantFromAddress); p.Number != "" && p.Number != known.self.Number {
+					others = append(others, p)
+				}
+			}
+			c := thread(others)
+
+			m := Message{Timestamp: time.UnixMilli(s.Date).In(loc), Sender: known.self.Name, SenderNumber: known.self.Number}
+			if s.MsgBox != androidSent {
+				for _, a := range s.Addrs {
+					if a.Type == mmsFrom {
+						p := known.contact(a.Address, "", participantFromMessage)
+						for _, q := range c.Participants {
+							if q.Number == p.Number {
+								p = q
+								break
+							}
+						}
+						c.Participants.add(p)
+						m.Sender, m.SenderNumber = p.Name, p.Number
+					}
+				}
+			}
+			var text []string
+			for _, part := range s.Parts {
+				switch {
+				case part.ContentType == "text/plain":
+					text = append(text, nullAttr(part.Text))
+				case part.ContentType == "application/smil":
+				default:
+					name := nullAttr(part.Name)
+					if name == "" {
+						name = nullAttr(part.Location)
+					}
+					if name == "" {
+						name = part.ContentType
+					}
+					m.Images = append(m.Images, name)
+				}
+			}
+			m.Content = strings.Join(text, "\n")
+			c.Messages = append(c.Messages, m)
+
+		case "call":
+			var s androidCall
+			if err := dec.DecodeElement(&s, &start); err != nil {
+				return nil, err
+			}
+			typ, ok := androidCallTypes[s.Type]
+			if !ok {
+				typ = unknownCallType
+			}
+			c := Conversation{
+				Type:       typ,
+				Timestamp:  time.UnixMilli(s.Date).In(loc),
+				Duration:   fmt.Sprintf("%02d:%02d:%02d", s.Duration/3600, s.Duration/60%60, s.Duration%60),
+				SourceFile: sourceFile,
+				Account:    account,
+				Origin:     androidOrigin,
+			}
+			c.Participants.add(known.contact(s.Number, s.ContactName, participantFromCall))
+			calls = append(calls, c)
+		}
+	}
+
+	var convs []Conversation
+	for _, key := range keys {
+		c := threads[key]
+		finishChat(c)
+		convs = append(convs, *c)
+	}
+	convs = append(convs, calls...)
+	for i := range convs {
+		convs[i].assignIDs()
+	}
+	return convs, nil
+}
+
+// nullAttr returns an attribute value, which SMS Backup & Restore writes as
+// "null" when it is unset.
+func nullAttr(s string) string {
+	if s == "null" {
+		return ""
+	}
+	return s
+}
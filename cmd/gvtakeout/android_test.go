@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := map[string]string{
+		"(555) 010-0199":   "+15550100199",
+		"15550100199":      "+15550100199",
+		"+44 20 7946 0000": "+442079460000",
+		"+333":             "+333",
+		"55555":            "55555",
+		"":                 "",
+	}
+	for in, want := range tests {
+		if got := normalizeNumber(in); got != want {
+			t.Errorf("normalizeNumber(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestParseAndroidBackup(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "android-calls.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	known := knownContacts{self: Participant{Name: selfName, Number: "+2222", IsSelf: true}}
+	convs, err := parseAndroidBackup(f, "android-calls.xml", "+2222", known, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][3]string
+	for _, c := range convs {
+		got = append(got, [3]string{c.Type, c.Duration, c.Participants[0].Name + " " + c.Participants[0].Number})
+		if c.Origin != androidOrigin || c.ID == "" {
+			t.Errorf("Expected an identified Android conversation, got %+v", c)
+		}
+	}
+	want := [][3]string{
+		{"placed_call", "00:02:05", "Tony S +333"},
+		{"missed_call", "00:00:00", "+15550100199 +15550100199"},
+		{"unknown_call", "00:00:00", "Unknown "},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected calls %v, got %v", want, got)
+	}
+}
+
+func TestImportAndroid(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "conversations.db")
+	w := &sqliteWriter{dbName: dbName}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	voice := parseTestdata(t, "sms.html", "mms.html")
+	for _, conv := range voice {
+		conv.Account = "+2222"
+		conv.Participants.markSelf(conv.Account)
+		if err := w.Write(conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runImportAndroid([]string{"-db", dbName, filepath.Join("testdata", "android-sms.xml"), filepath.Join("testdata", "android-calls.xml")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openReadOnly(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sms, mms := voice[0], voice[1]
+	for _, c := range []*Conversation{&sms, &mms} {
+		c.Account = "+2222"
+		c.Participants.markSelf(c.Account)
+		c.assignIDs()
+	}
+
+	counts := func(thread string) (convs, msgs int) {
+		t.Helper()
+		err := db.QueryRow(`SELECT COUNT(DISTINCT conversation.id), COUNT(message.id)
+			FROM conversation LEFT JOIN message ON message.conversation_id = conversation.id
+			WHERE conversation.thread_uid = ? AND conversation.type = 'chat'`, thread).Scan(&convs, &msgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return convs, msgs
+	}
+	if convs, msgs := counts(sms.ThreadID); convs != 1 || msgs != len(sms.Messages) {
+		t.Errorf("Expected the phone's copies of the Voice texts to be dropped, got %d conversations and %d messages", convs, msgs)
+	}
+	if convs, msgs := counts(mms.ThreadID); convs != 2 || msgs != len(mms.Messages)+1 {
+		t.Errorf("Expected the group MMS in the Voice group's thread, got %d conversations and %d messages", convs, msgs)
+	}
+
+	var dups int
+	if err := db.QueryRow("SELECT COUNT(*) FROM duplicate_message WHERE source_file = 'android-sms.xml'").Scan(&dups); err != nil {
+		t.Fatal(err)
+	}
+	if dups != 3 {
+		t.Errorf("Expected 3 duplicates from the backup, got %d", dups)
+	}
+
+	var sender, content string
+	err = db.QueryRow(`SELECT contact.name, message.content FROM message
+		JOIN contact ON contact.id = message.sender_contact_id
+		JOIN image ON image.message_id = message.id
+		WHERE image.image_url = 'IMG_0001.jpg'`).Scan(&sender, &content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender != "Mike Truk" || content != "Look at this hornet nest" {
+		t.Errorf("Expected the MMS from Mike Truk with its text, got %q %q", sender, content)
+	}
+
+	drafts, err := queryStrings(db, "SELECT content FROM message WHERE content LIKE 'draft%'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != 0 {
+		t.Errorf("Expected drafts to be skipped, got %v", drafts)
+	}
+
+	calls, err := queryStrings(db, "SELECT type FROM conversation WHERE origin = 'android' AND type != 'chat' ORDER BY timestamp_ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(calls, []string{"placed_call", "missed_call", "unknown_call"}) {
+		t.Errorf("Expected the call log, got %v", calls)
+	}
+}
+
+func TestAndroidAttachmentsWithoutMediaFiles(t *testing.T) {
+	const backup = `<smses count="1">
+  <mms date="1656871200000" msg_box="1" address="+333" contact_name="Tony S">
+    <parts>
+      <part seq="0" ct="image/png" name="null" cl="null" text="null" data="iVBORw0KGgo=" />
+      <part seq="1" ct="image/jpeg" name="photo.jpg" cl="photo.jpg" text="null" data="/9j/4AAQSkZJRg==" />
+    </parts>
+    <addrs>
+      <addr address="+333" type="137" />
+    </addrs>
+  </mms>
+</smses>`
+	known := knownContacts{self: Participant{Name: selfName, Number: "+2222", IsSelf: true}}
+	convs, err := parseAndroidBackup(strings.NewReader(backup), "backup.xml", "+2222", known, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 1 || len(convs[0].Messages) != 1 {
+		t.Fatalf("Expected one MMS, got %+v", convs)
+	}
+	if got := convs[0].Messages[0].Images; !reflect.DeepEqual(got, []string{"image/png", "photo.jpg"}) {
+		t.Errorf("Expected the unnamed part listed by its type, got %q", got)
+	}
+
+	// Files in the working directory are not the backup's attachments,
+	// even when the names match.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"photo.jpg", "conversations.db"} {
+		if err := os.WriteFile(name, []byte("not an attachment"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := importConversations(t, convs)
+	var images, files int
+	if err := db.QueryRow("SELECT (SELECT COUNT(*) FROM image), (SELECT COUNT(*) FROM media_file)").Scan(&images, &files); err != nil {
+		t.Fatal(err)
+	}
+	if images != 2 || files != 0 {
+		t.Errorf("Expected 2 images and no media files, got %d and %d", images, files)
+	}
+
+	if _, err := findMediaFile(""); !errors.Is(err, errNoMediaFile) {
+		t.Errorf("Expected no media file for an empty name, got %v", err)
+	}
+}
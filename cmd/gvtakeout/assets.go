@@ -0,0 +1,70 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+//go:embed templates/*.html static
+var embeddedAssets embed.FS
+
+// loadAssets parses the embedded templates and returns the static file
+// system. Files in overrideDir, if set, take precedence: any *.html there
+// replaces the embedded template of the same name, and files under
+// overrideDir/static replace or add to the embedded static assets.
+func loadAssets(overrideDir string) (*template.Template, fs.FS, error) {
+	tmpl, err := template.ParseFS(embeddedAssets, "templates/*.html")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	static, err := fs.Sub(embeddedAssets, "static")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if overrideDir == "" {
+		return tmpl, static, nil
+	}
+
+	st, err := os.Stat(overrideDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !st.IsDir() {
+		return nil, nil, fmt.Errorf("%s is not a directory", overrideDir)
+	}
+
+	overrides, err := filepath.Glob(filepath.Join(overrideDir, "*.html"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(overrides) > 0 {
+		if _, err := tmpl.ParseFiles(overrides...); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return tmpl, overlayFS{os.DirFS(filepath.Join(overrideDir, "static")), static}, nil
+}
+
+// overlayFS opens each name from the first file system that has it.
+type overlayFS []fs.FS
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	for _, fsys := range o {
+		f, err := fsys.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
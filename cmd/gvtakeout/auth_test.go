@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func TestRequirePassword(t *testing.T) {
+	h := requirePassword(okHandler, "hunter2")
+
+	for _, tc := range []struct {
+		name     string
+		password string
+		auth     bool
+		want     int
+	}{
+		{"missing", "", false, http.StatusUnauthorized},
+		{"wrong", "hunter3", true, http.StatusUnauthorized},
+		{"right", "hunter2", true, http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tc.auth {
+			req.SetBasicAuth("anyone", tc.password)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s password: Expected %d, got %d", tc.name, tc.want, rec.Code)
+		}
+		if tc.want == http.StatusUnauthorized && !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), `Basic realm="gvtakeout"`) {
+			t.Errorf("%s password: Expected a basic auth challenge, got %q", tc.name, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	h := requireToken(okHandler, "s3cret")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/calls?account=%2B2222&token=s3cret", nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("Expected a redirect, got %d", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/calls?account=%2B2222" {
+		t.Errorf("Expected a redirect to the page without the token, got %q", loc)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != tokenCookie || cookies[0].Value != "s3cret" || !cookies[0].HttpOnly {
+		t.Fatalf("Expected an HttpOnly token cookie, got %+v", cookies)
+	}
+
+	req := httptest.NewRequest("GET", "/calls", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("Expected the cookie to log in, got %d", rec.Code)
+	}
+
+	for name, req := range map[string]*http.Request{
+		"no token":    httptest.NewRequest("GET", "/", nil),
+		"wrong token": httptest.NewRequest("GET", "/?token=guess", nil),
+		"bad cookie":  httptest.NewRequest("GET", "/", nil),
+	} {
+		if name == "bad cookie" {
+			req.AddCookie(&http.Cookie{Name: tokenCookie, Value: "guess"})
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: Expected 401, got %d", name, rec.Code)
+		}
+		if len(rec.Result().Cookies()) != 0 {
+			t.Errorf("%s: Expected no cookie, got %+v", name, rec.Result().Cookies())
+		}
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	securityHeaders(okHandler).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	want := map[string]string{
+		"Content-Security-Policy": contentSecurityPolicy,
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "no-referrer",
+		"Cache-Control":           "no-store",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("%s: Expected %q, got %q", k, v, got)
+		}
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"localhost:8080": true,
+		"localhost":      true,
+		"[::1]:80":       true,
+		":8080":          false,
+		"0.0.0.0:80":     false,
+		"[::]:80":        false,
+		"192.0.2.1:8080": false,
+	} {
+		if got := isLoopback(addr); got != want {
+			t.Errorf("isLoopback(%q): Expected %v, got %v", addr, want, got)
+		}
+	}
+}
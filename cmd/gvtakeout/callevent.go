@@ -0,0 +1,202 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// callEventWindow is how far apart a missed call and the voicemail left on
+// it may be. Google records them as separate files, usually seconds apart.
+const callEventWindow = 2 * time.Minute
+
+// CallEvent links a missed call with the voicemail the caller left. Both
+// conversations carry the same event.
+type CallEvent struct {
+	Number     string  `json:"number"`
+	MissedCall CallRef `json:"missed_call"`
+	Voicemail  CallRef `json:"voicemail"`
+}
+
+// CallRef identifies one of the conversations of a call event.
+type CallRef struct {
+	Timestamp  time.Time `json:"timestamp"`
+	SourceFile string    `json:"source_file"`
+}
+
+// callRecord is what correlateCalls needs to know about a call.
+type callRecord struct {
+	account   string
+	typ       string
+	number    string
+	timestamp time.Time
+}
+
+// callNumber returns the number of the other party to a call.
+func callNumber(conv Conversation) string {
+	for _, p := range conv.Participants {
+		if !p.IsSelf && p.Number != "" {
+			return p.Number
+		}
+	}
+	return ""
+}
+
+// correlateCalls pairs missed calls with voicemails from the same number on
+// the same account within callEventWindow of each other, closest pairs
+// first. It returns {missed call, voicemail} index pairs into calls, in
+// order of the missed call.
+func correlateCalls(calls []callRecord) [][2]int {
+	voicemails := make(map[string][]int)
+	for i, c := range calls {
+		if c.typ == "voicemail" && c.number != "" {
+			key := c.account + "\x00" + digitsOnly(c.number)
+			voicemails[key] = append(voicemails[key], i)
+		}
+	}
+
+	type candidate struct {
+		missed, voicemail int
+		gap               time.Duration
+	}
+	var candidates []candidate
+	for i, c := range calls {
+		if c.typ != "missed_call" || c.number == "" {
+			continue
+		}
+		for _, j := range voicemails[c.account+"\x00"+digitsOnly(c.number)] {
+			gap := calls[j].timestamp.Sub(c.timestamp).Abs()
+			if gap <= callEventWindow {
+				candidates = append(candidates, candidate{i, j, gap})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].gap < candidates[b].gap
+	})
+
+	used := make(map[int]bool)
+	var pairs [][2]int
+	for _, c := range candidates {
+		if used[c.missed] || used[c.voicemail] {
+			continue
+		}
+		used[c.missed], used[c.voicemail] = true, true
+		pairs = append(pairs, [2]int{c.missed, c.voicemail})
+	}
+	sort.Slice(pairs, func(a, b int) bool { return pairs[a][0] < pairs[b][0] })
+	return pairs
+}
+
+// isCorrelatedCall reports whether conversations of this type can be part
+// of a call event.
+func isCorrelatedCall(typ string) bool {
+	return typ == "missed_call" || typ == "voicemail"
+}
+
+// linkCalls sets the CallEvent of the missed calls and voicemails in convs
+// that belong together.
+func linkCalls(convs []Conversation) {
+	calls := make([]callRecord, len(convs))
+	for i, c := range convs {
+		calls[i] = callRecord{account: c.Account, typ: c.Type, number: callNumber(c), timestamp: c.Timestamp}
+	}
+	for _, p := range correlateCalls(calls) {
+		missed, vm := &convs[p[0]], &convs[p[1]]
+		ev := &CallEvent{
+			Number:     callNumber(*missed),
+			MissedCall: CallRef{Timestamp: missed.Timestamp, SourceFile: missed.SourceFile},
+			Voicemail:  CallRef{Timestamp: vm.Timestamp, SourceFile: vm.SourceFile},
+		}
+		missed.CallEvent, vm.CallEvent = ev, ev
+	}
+}
+
+// linkCallEvents rebuilds the call_event table from every missed call and
+// voicemail in the database, so calls from separate imports are linked too.
+func linkCallEvents(db *sql.DB) error {
+	rows, err := db.Query(`SELECT c.id, c.account, c.type, c.timestamp_ms,
+			COALESCE((SELECT contact.phone_number FROM participant JOIN contact ON contact.id = participant.contact_id
+				WHERE participant.conversation_id = c.id AND NOT participant.is_self AND contact.phone_number != ''
+				ORDER BY participant.id LIMIT 1), '')
+		FROM conversation c
+		WHERE c.type IN ('missed_call', 'voicemail') AND c.timestamp_ms IS NOT NULL
+		ORDER BY c.timestamp_ms, c.id`)
+	if err != nil {
+		return fmt.Errorf("failed to query calls: %w", err)
+	}
+	var (
+		ids   []int64
+		calls []callRecord
+	)
+	for rows.Next() {
+		var (
+			id int64
+			ms int64
+			c  callRecord
+		)
+		if err := rows.Scan(&id, &c.account, &c.typ, &ms, &c.number); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan call row: %w", err)
+		}
+		c.timestamp = time.UnixMilli(ms)
+		ids = append(ids, id)
+		calls = append(calls, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating call rows: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM call_event"); err != nil {
+		return fmt.Errorf("failed to clear call events: %w", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO call_event (account, phone_number, missed_call_id, voicemail_id) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare call event statement: %w", err)
+	}
+	defer stmt.Close()
+	for _, p := range correlateCalls(calls) {
+		missed := calls[p[0]]
+		if _, err := stmt.Exec(missed.account, missed.number, ids[p[0]], ids[p[1]]); err != nil {
+			return fmt.Errorf("failed to insert call event: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// loadCallEvent returns the call event a stored conversation is part of, or
+// nil.
+func loadCallEvent(db *sql.DB, convID int64) (*CallEvent, error) {
+	var (
+		ev               CallEvent
+		mMs, mOffset     sql.NullInt64
+		vMs, vOffset     sql.NullInt64
+		mSource, vSource string
+	)
+	err := db.QueryRow(`SELECT ce.phone_number, m.timestamp_ms, m.utc_offset, m.source_file, v.timestamp_ms, v.utc_offset, v.source_file
+		FROM call_event ce
+		JOIN conversation m ON m.id = ce.missed_call_id
+		JOIN conversation v ON v.id = ce.voicemail_id
+		WHERE ce.missed_call_id = ? OR ce.voicemail_id = ?`, convID, convID).
+		Scan(&ev.Number, &mMs, &mOffset, &mSource, &vMs, &vOffset, &vSource)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query call event: %w", err)
+	}
+	ev.MissedCall = CallRef{Timestamp: storedTime(mMs, mOffset), SourceFile: mSource}
+	ev.Voicemail = CallRef{Timestamp: storedTime(vMs, vOffset), SourceFile: vSource}
+	return &ev, nil
+}
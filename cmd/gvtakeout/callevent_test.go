@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCorrelateCalls(t *testing.T) {
+	ts := time.Date(2018, 8, 1, 19, 0, 0, 0, time.UTC)
+	calls := []callRecord{
+		{typ: "missed_call", number: "+15550100", timestamp: ts},
+		{typ: "missed_call", number: "+15550100", timestamp: ts.Add(30 * time.Second)},
+		{typ: "voicemail", number: "+1 555-0100", timestamp: ts.Add(40 * time.Second)},
+		{typ: "voicemail", number: "+15550100", timestamp: ts.Add(time.Hour)},
+		{typ: "missed_call", number: "+15550199", timestamp: ts},
+		{typ: "missed_call", account: "+2222", number: "+15550142", timestamp: ts},
+		{typ: "voicemail", account: "+3333", number: "+15550142", timestamp: ts},
+		{typ: "missed_call", number: "+15550142", timestamp: ts},
+		{typ: "voicemail", number: "+15550142", timestamp: ts.Add(-5 * time.Second)},
+	}
+	got := correlateCalls(calls)
+	want := [][2]int{{1, 2}, {7, 8}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected pairs %v, got %v", want, got)
+	}
+}
+
+func TestCallEvents(t *testing.T) {
+	names := []string{"missedcall.html", "missedcall-voicemail.html", "voicemail-notranscript.html", "voicemail.html"}
+	convs := parseTestdata(t, names...)
+	for i := range convs {
+		convs[i].Account = "+2222"
+	}
+	linkCalls(convs)
+	if convs[0].CallEvent != nil || convs[3].CallEvent != nil {
+		t.Errorf("Expected unrelated calls to stay unlinked, got %+v and %+v", convs[0].CallEvent, convs[3].CallEvent)
+	}
+	ev := convs[1].CallEvent
+	if ev == nil || ev != convs[2].CallEvent || ev.MissedCall.SourceFile != "missedcall-voicemail.html" || ev.Voicemail.SourceFile != "voicemail-notranscript.html" {
+		t.Fatalf("Expected the missed call and voicemail to share an event, got %+v and %+v", convs[1].CallEvent, convs[2].CallEvent)
+	}
+
+	db := importTestdata(t, names...)
+	var linked int
+	for _, id := range []int64{1, 2, 3, 4} {
+		conv, err := loadConversation(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conv.CallEvent == nil {
+			continue
+		}
+		linked++
+		if !conv.CallEvent.Voicemail.Timestamp.Equal(ev.Voicemail.Timestamp) || conv.CallEvent.Number != ev.Number {
+			t.Errorf("Expected stored event %+v, got %+v", ev, conv.CallEvent)
+		}
+	}
+	if linked != 2 {
+		t.Errorf("Expected 2 linked conversations in the database, got %d", linked)
+	}
+}
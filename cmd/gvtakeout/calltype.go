@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// Call files say what kind of call they are in the takeout's language, so
+// the type is taken from the most reliable locale-independent signal
+// available:
+//
+//  1. the label links, whose hrefs (e.g. "voice#missed") aren't translated
+//  2. the file name, e.g. "Name - Missed - 2009-09-17T00_26_41Z.html"
+//  3. a voicemail transcript
+//  4. the last text of the call that matches one of callPhrases
+//
+// A call with none of these is an unknown_call rather than being dropped.
+// callTypeSignals collects the signals while a call is parsed.
+type callTypeSignals struct {
+	tag        string
+	file       string
+	transcript bool
+	text       string
+}
+
+// unknownCallType is the type of a call none of the signals recognize.
+const unknownCallType = "unknown_call"
+
+// resolve returns the call type from the strongest signal.
+func (s callTypeSignals) resolve() string {
+	switch {
+	case s.tag != "":
+		return s.tag
+	case s.file != "":
+		return s.file
+	case s.transcript:
+		return "voicemail"
+	case s.text != "":
+		return s.text
+	}
+	return unknownCallType
+}
+
+// callTagTypes maps label href fragments to call types. A voicemail is also
+// labelled with its inbox, so the order of precedence is the order here.
+var callTagTypes = []struct {
+	fragment string
+	typ      string
+}{
+	{"voicemail", "voicemail"},
+	{"recorded", "recorded_call"},
+	{"missed", "missed_call"},
+	{"placed", "placed_call"},
+	{"received", "received_call"},
+}
+
+// callTypeFromTags returns the call type given by the fragments of a call's
+// label hrefs.
+func callTypeFromTags(fragments []string) string {
+	for _, t := range callTagTypes {
+		for _, f := range fragments {
+			if f == t.fragment {
+				return t.typ
+			}
+		}
+	}
+	return ""
+}
+
+// tagFragment returns the fragment of a label href, e.g. "missed" for
+// "http://www.google.com/voice#missed".
+func tagFragment(href string) string {
+	if i := strings.LastIndexByte(href, '#'); i >= 0 {
+		return href[i+1:]
+	}
+	return ""
+}
+
+// callFileTypes maps the middle part of Google's call file names, which is
+// in English whatever the takeout's language, to call types.
+var callFileTypes = map[string]string{
+	"Voicemail": "voicemail",
+	"Missed":    "missed_call",
+	"Placed":    "placed_call",
+	"Received":  "received_call",
+	"Recorded":  "recorded_call",
+}
+
+// callTypeFromFileName returns the call type given by a file named
+// "<contact> - <type> - <timestamp>.html".
+func callTypeFromFileName(name string) string {
+	parts := strings.Split(strings.TrimSuffix(filepath.Base(name), ".html"), " - ")
+	if len(parts) < 3 {
+		return ""
+	}
+	return callFileTypes[parts[len(parts)-2]]
+}
+
+// callPhrases are the headings of call files in the languages we have seen,
+// e.g. "Missed call from". A phrase that contains another, like Japanese
+// 不在着信 (missed call) and 着信 (received call), must come first.
+var callPhrases = []struct {
+	text []byte
+	typ  string
+}{
+	// English
+	{[]byte("Voicemail"), "voicemail"},
+	{[]byte("Placed call"), "placed_call"},
+	{[]byte("Received call"), "received_call"},
+	{[]byte("Missed call"), "missed_call"},
+	{[]byte("Recorded call"), "recorded_call"},
+	// German
+	{[]byte("Mailbox-Nachricht"), "voicemail"},
+	{[]byte("Ausgehender Anruf"), "placed_call"},
+	{[]byte("Eingehender Anruf"), "received_call"},
+	{[]byte("Verpasster Anruf"), "missed_call"},
+	{[]byte("Aufgezeichneter Anruf"), "recorded_call"},
+	// Spanish
+	{[]byte("Mensaje de voz"), "voicemail"},
+	{[]byte("Llamada realizada"), "placed_call"},
+	{[]byte("Llamada recibida"), "received_call"},
+	{[]byte("Llamada perdida"), "missed_call"},
+	{[]byte("Llamada grabada"), "recorded_call"},
+	// French
+	{[]byte("Message vocal"), "voicemail"},
+	{[]byte("Appel passé"), "placed_call"},
+	{[]byte("Appel reçu"), "received_call"},
+	{[]byte("Appel manqué"), "missed_call"},
+	{[]byte("Appel enregistré"), "recorded_call"},
+	// Japanese
+	{[]byte("ボイスメール"), "voicemail"},
+	{[]byte("録音された通話"), "recorded_call"},
+	{[]byte("不在着信"), "missed_call"},
+	{[]byte("発信"), "placed_call"},
+	{[]byte("着信"), "received_call"},
+}
+
+// callTypeFromText returns the call type named by a text node of a call.
+func callTypeFromText(text []byte) string {
+	for _, p := range callPhrases {
+		if bytes.Contains(text, p.text) {
+			return p.typ
+		}
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLocales(t *testing.T) {
+	tests := []struct {
+		file   string
+		typ    string
+		name   string
+		number string
+	}{
+		// Label hrefs.
+		{"de-missed.html", "missed_call", "Max Mustermann", "+4930123456"},
+		{"es-voicemail.html", "voicemail", "Juan Pérez", "+34911222333"},
+		// No labels: the translation table.
+		{"ja-received.html", "received_call", "山田太郎", "+81312345678"},
+		// No labels and untranslated text: the file name.
+		{"Kim Minji - Placed - 2021-03-04T05_06_07Z.html", "placed_call", "김민지", "+82212345678"},
+		// Only a transcript.
+		{"ko-voicemail.html", "voicemail", "김민지", "+82212345678"},
+	}
+
+	for _, tt := range tests {
+		input, err := os.ReadFile(filepath.Join("testdata/locales", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conv, err := parseFile(slog.Default(), bytes.NewReader(input), tt.file)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if conv.Type != tt.typ {
+			t.Errorf("%s: Expected type %s, got %q", tt.file, tt.typ, conv.Type)
+		}
+		if len(conv.Participants) != 1 || conv.Participants[0].Name != tt.name || conv.Participants[0].Number != tt.number {
+			t.Errorf("%s: Expected participant %s %s, got %v", tt.file, tt.name, tt.number, conv.Participants)
+		}
+		if conv.Timestamp.IsZero() {
+			t.Errorf("%s: Expected a timestamp", tt.file)
+		}
+	}
+}
+
+func TestCallTypeSignals(t *testing.T) {
+	fileTests := map[string]string{
+		"Dwigt Rortugal - Missed - 2009-09-17T00_26_41Z.html":       "missed_call",
+		"Calls/+15551234567 - Received - 2020-01-01T00_00_00Z.html": "received_call",
+		"A - B - Voicemail - 2020-01-01T00_00_00Z.html":             "voicemail",
+		"Tony Smehrik - Text - 2022-07-01T01_06_39Z.html":           "",
+		"missedcall.html": "",
+	}
+	for name, want := range fileTests {
+		if got := callTypeFromFileName(name); got != want {
+			t.Errorf("callTypeFromFileName(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	if got := callTypeFromTags([]string{"inbox", "voicemail"}); got != "voicemail" {
+		t.Errorf("Expected voicemail from tags, got %q", got)
+	}
+	if got := callTypeFromText([]byte("不在着信")); got != "missed_call" {
+		t.Errorf("Expected 不在着信 to be a missed call rather than a received one, got %q", got)
+	}
+
+	// Of several texts naming a type, the last one wins.
+	call := `<html><head><title>Tony Smehrik</title></head><body><div class="haudio">
+<span class="fn">Placed call to Tony Smehrik</span>
+<div class="contributor vcard">Received call from
+<a class="tel" href="tel:+333"><span class="fn">Tony Smehrik</span></a></div>
+<abbr class="published" title="2020-01-01T00:00:00.000Z">Jan 1, 2020</abbr>
+</div></body></html>`
+	conv, err := parseFile(slog.Default(), strings.NewReader(call), "call.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.Type != "received_call" {
+		t.Errorf("Expected the last text to give received_call, got %q", conv.Type)
+	}
+
+	s := callTypeSignals{file: "placed_call", transcript: true, text: "voicemail"}
+	if got := s.resolve(); got != "placed_call" {
+		t.Errorf("Expected the file name to win over the transcript and text, got %q", got)
+	}
+	s.tag = "missed_call"
+	if got := s.resolve(); got != "missed_call" {
+		t.Errorf("Expected the tag to win, got %q", got)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Google Chat, which replaced Hangouts, exports a "Google Chat" directory
+// with a Groups/<group>/messages.json and group_info.json per conversation
+// and the account's own details in Users/<user>/user_info.json. Members are
+// named by email rather than phone number, so Google Chat conversations
+// form threads of their own.
+
+// googleChatOrigin is the Conversation.Origin of conversations from a
+// Google Chat export.
+const googleChatOrigin = "google_chat"
+
+// googleChatLayout is the format of created_date, less the zone
+// abbreviation that ends it. Newer exports put a narrow no-break space
+// before the AM/PM marker.
+const googleChatLayout = "Monday, January 2, 2006 at 3:04:05 PM"
+
+// googleChatZones are the offsets, in minutes east of UTC, of the zone
+// abbreviations Google writes in created_date. time.Parse only knows the
+// offset of the machine's own zone's abbreviations and takes any other as
+// UTC, which would make timestamps and message IDs depend on where the
+// export is imported.
+var googleChatZones = map[string]int{
+	"UTC": 0, "GMT": 0, "Z": 0,
+	"EST": -5 * 60, "EDT": -4 * 60,
+	"CST": -6 * 60, "CDT": -5 * 60,
+	"MST": -7 * 60, "MDT": -6 * 60,
+	"PST": -8 * 60, "PDT": -7 * 60,
+	"AKST": -9 * 60, "AKDT": -8 * 60,
+	"HST": -10 * 60,
+	"WET": 0, "WEST": 60, "BST": 60,
+	"CET": 60, "CEST": 2 * 60,
+	"EET": 2 * 60, "EEST": 3 * 60,
+	"MSK": 3 * 60,
+	"IST": 5*60 + 30,
+	"JST": 9 * 60, "KST": 9 * 60,
+	"AWST": 8 * 60,
+	"ACST": 9*60 + 30, "ACDT": 10*60 + 30,
+	"AEST": 10 * 60, "AEDT": 11 * 60,
+	"NZST": 12 * 60, "NZDT": 13 * 60,
+}
+
+type googleChatUser struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type googleChatMessages struct {
+	Messages []struct {
+		Creator       googleChatUser `json:"creator"`
+		CreatedDate   string         `json:"created_date"`
+		Text          string         `json:"text"`
+		AttachedFiles []struct {
+			OriginalName string `json:"original_name"`
+			ExportName   string `json:"export_name"`
+		} `json:"attached_files"`
+	} `json:"messages"`
+}
+
+type googleChatGroupInfo struct {
+	Name    string           `json:"name"`
+	Members []googleChatUser `json:"members"`
+}
+
+type googleChatUserInfo struct {
+	User googleChatUser `json:"user"`
+}
+
+func runImportChat(args []string) error {
+	return runExternalImport("import-chat", "<Google Chat dir | messages.json>...", args, parseGoogleChat)
+}
+
+// parseGoogleChat reads a Google Chat export, one of its group directories,
+// or a group's messages.json. Each group is one chat.
+func parseGoogleChat(path, account string, known knownContacts, loc *time.Location) ([]Conversation, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if fi.IsDir() {
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && d.Name() == "messages.json" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		files = []string{path}
+	}
+
+	var convs []Conversation
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		self, err := googleChatSelf(dir)
+		if err != nil {
+			return nil, err
+		}
+		conv, err := parseGoogleChatGroup(f, self, account, known, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		if len(conv.Messages) > 0 {
+			convs = append(convs, conv)
+		}
+	}
+	return convs, nil
+}
+
+// googleChatSelf returns the account owner's email from the Users directory
+// of the export a group directory belongs to, or "" if it can't be found.
+func googleChatSelf(groupDir string) (string, error) {
+	root := filepath.Dir(filepath.Dir(groupDir))
+	matches, err := filepath.Glob(filepath.Join(root, "Users", "*", "user_info.json"))
+	if err != nil || len(matches) == 0 {
+		return "", err
+	}
+	var info googleChatUserInfo
+	if err := readJSONFile(matches[0], &info); err != nil {
+		return "", err
+	}
+	return info.User.Email, nil
+}
+
+func parseGoogleChatGroup(file, self, account string, known knownContacts, loc *time.Location) (Conversation, error) {
+	dir := filepath.Dir(file)
+	conv := Conversation{
+		Type:       "chat",
+		SourceFile: filepath.Join(filepath.Base(dir), filepath.Base(file)),
+		Account:    account,
+		Origin:     googleChatOrigin,
+	}
+	person := func(u googleChatUser, source string) Participant {
+		if self != "" && strings.EqualFold(u.Email, self) {
+			return known.me(source)
+		}
+		name := u.Name
+		if name == "" {
+			name = u.Email
+		}
+		if name == "" {
+			name = "Unknown"
+		}
+		return Participant{Name: name, Source: source}
+	}
+
+	var info googleChatGroupInfo
+	err := readJSONFile(filepath.Join(dir, "group_info.json"), &info)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return conv, err
+	}
+	for _, u := range info.Members {
+		conv.Participants.add(person(u, participantFromGroup))
+	}
+
+	var msgs googleChatMessages
+	if err := readJSONFile(file, &msgs); err != nil {
+		return conv, err
+	}
+	for _, gm := range msgs.Messages {
+		ts, err := parseGoogleChatDate(gm.CreatedDate, loc)
+		if err != nil {
+			return conv, err
+		}
+		sender := person(gm.Creator, participantFromMessage)
+		conv.Participants.add(sender)
+		m := Message{
+			Timestamp:    ts.In(loc),
+			Sender:       sender.Name,
+			SenderNumber: sender.Number,
+			Content:      gm.Text,
+		}
+		for _, a := range gm.AttachedFiles {
+			name := a.ExportName
+			if name == "" {
+				name = a.OriginalName
+			}
+			m.Images = append(m.Images, name)
+		}
+		conv.Messages = append(conv.Messages, m)
+	}
+
+	finishChat(&conv)
+	conv.assignIDs()
+	return conv, nil
+}
+
+// parseGoogleChatDate reads a created_date. Its zone is a known
+// abbreviation, an offset such as GMT+5:30, or otherwise taken to be loc.
+func parseGoogleChatDate(s string, loc *time.Location) (time.Time, error) {
+	s = strings.ReplaceAll(s, "\u202f", " ")
+	i := strings.LastIndex(s, " ")
+	if i < 0 {
+		return time.Time{}, fmt.Errorf("bad created_date %q", s)
+	}
+	when, zone := s[:i], s[i+1:]
+	if offset, ok := googleChatZoneOffset(zone); ok {
+		loc = time.FixedZone(zone, offset*60)
+	}
+	t, err := time.ParseInLocation(googleChatLayout, when, loc)
+	if err != nil {
+		return t, fmt.Errorf("bad created_date %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// googleChatZoneOffset returns the offset in minutes of a zone abbreviation
+// or of a GMT+h[:mm] or UTC-h[:mm] offset.
+func googleChatZoneOffset(zone string) (int, bool) {
+	if offset, ok := googleChatZones[zone]; ok {
+		return offset, true
+	}
+	for _, prefix := range []string{"GMT", "UTC"} {
+		rest, ok := strings.CutPrefix(zone, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		sign := 1
+		switch rest[0] {
+		case '-':
+			sign = -1
+		case '+':
+		default:
+			return 0, false
+		}
+		h, m, _ := strings.Cut(rest[1:], ":")
+		hours, err := strconv.Atoi(h)
+		if err != nil {
+			return 0, false
+		}
+		minutes := 0
+		if m != "" {
+			if minutes, err = strconv.Atoi(m); err != nil {
+				return 0, false
+			}
+		}
+		return sign * (hours*60 + minutes), true
+	}
+	return 0, false
+}
+
+func readJSONFile(name string, v any) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseGoogleChat(t *testing.T) {
+	known := knownContacts{self: Participant{Name: selfName, Number: "+2222", IsSelf: true}}
+	dir := filepath.Join("testdata", "Google Chat")
+	convs, err := parseGoogleChat(dir, "+2222", known, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 1 {
+		t.Fatalf("Expected one conversation, got %d", len(convs))
+	}
+	c := convs[0]
+	if c.Origin != googleChatOrigin || c.SourceFile != filepath.Join("DM 8fZpNEAAAAE", "messages.json") {
+		t.Errorf("Expected a Google Chat conversation from the group's messages.json, got %q %q", c.Origin, c.SourceFile)
+	}
+
+	var names []string
+	for _, p := range c.Participants {
+		names = append(names, p.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Me", "Mike Truk"}) {
+		t.Errorf("Expected the group's members with the account owner as Me, got %v", names)
+	}
+
+	want := []Message{
+		{Timestamp: time.Date(2020, 3, 2, 18, 6, 1, 0, time.UTC), Sender: "Mike Truk", Content: "are we still on for friday?"},
+		{Timestamp: time.Date(2020, 3, 3, 9, 15, 30, 0, time.UTC), Sender: "Me", SenderNumber: "+2222", Content: "yes, here is the map", Images: []string{"File-map.png"}},
+	}
+	for i := range c.Messages {
+		c.Messages[i].ID = ""
+	}
+	if !reflect.DeepEqual(c.Messages, want) {
+		t.Errorf("Expected messages %+v, got %+v", want, c.Messages)
+	}
+
+	// A single messages.json still finds the export's user_info.json.
+	single, err := parseGoogleChat(filepath.Join(dir, "Groups", "DM 8fZpNEAAAAE", "messages.json"), "+2222", known, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(single) != 1 || single[0].ID != c.ID {
+		t.Errorf("Expected the same conversation from its messages.json, got %+v", single)
+	}
+}
+
+func TestParseGoogleChatDate(t *testing.T) {
+	eastern := time.FixedZone("Eastern", -5*60*60)
+	for _, tc := range []struct {
+		in   string
+		want time.Time
+	}{
+		{"Monday, March 2, 2020 at 6:06:01 PM UTC", time.Date(2020, 3, 2, 18, 6, 1, 0, time.UTC)},
+		{"Monday, March 2, 2020 at 6:06:01 PM PST", time.Date(2020, 3, 3, 2, 6, 1, 0, time.UTC)},
+		{"Monday, July 6, 2020 at 6:06:01 PM PDT", time.Date(2020, 7, 7, 1, 6, 1, 0, time.UTC)},
+		{"Monday, March 2, 2020 at 6:06:01 PM CET", time.Date(2020, 3, 2, 17, 6, 1, 0, time.UTC)},
+		{"Monday, March 2, 2020 at 6:06:01 PM GMT+5:30", time.Date(2020, 3, 2, 12, 36, 1, 0, time.UTC)},
+		{"Monday, March 2, 2020 at 6:06:01 PM UTC-3", time.Date(2020, 3, 2, 21, 6, 1, 0, time.UTC)},
+		// An unknown abbreviation is read in the -tz location.
+		{"Monday, March 2, 2020 at 6:06:01 PM XYZ", time.Date(2020, 3, 2, 23, 6, 1, 0, time.UTC)},
+	} {
+		got, err := parseGoogleChatDate(tc.in, eastern)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%q: Expected %v, got %v", tc.in, tc.want, got.UTC())
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A Google Contacts export, as a vCard or Google CSV file, gives the names
+// takeouts leave out: Voice often names a contact by number alone, or gives
+// no number at all. import-contacts keeps what it learns apart from the
+// parsed contact rows, in contact_detail and contact_phone, and the viewer
+// reads names through the contact_display view so the enriched name is
+// shown while contact.name stays as parsed.
+//
+// Photos embedded in a vCard are stored and served by the viewer. Google's
+// exports usually link to photos instead; the links are stored, but the
+// viewer doesn't load them since it makes no requests to other sites.
+
+// GoogleContact is one person from a Google Contacts export.
+type GoogleContact struct {
+	Name         string
+	Organization string
+	PhotoURL     string
+	// PhotoType and PhotoData are a photo embedded in the export.
+	PhotoType string
+	PhotoData []byte
+	Phones    []ContactPhone
+}
+
+// ContactPhone is one of a GoogleContact's numbers, normalized as Voice
+// writes them.
+type ContactPhone struct {
+	Number string
+	Label  string
+}
+
+func runImportContacts(args []string) error {
+	fs, common := newFlagSet("import-contacts", "<contacts.vcf | contacts.csv>...")
+	account := fs.String("account", "", "Only enrich this account's contacts (default: every account)")
+	var encFlags encryptFlags
+	encFlags.register(fs)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("nothing to import")
+	}
+	if _, err := common.setup(); err != nil {
+		return err
+	}
+
+	// Contacts only enrich conversations already imported, so a mistyped
+	// -db is an error rather than a new, empty database. Opening it checks
+	// its schema.
+	path := common.db
+	if encFlags.encrypt && !strings.HasSuffix(path, ".age") {
+		path += ".age"
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("import-contacts needs an existing database: %w", err)
+	}
+	w, err := openImportWriter(common, encFlags)
+	if err != nil {
+		return err
+	}
+	for _, path := range fs.Args() {
+		contacts, err := parseGoogleContactsFile(path)
+		if err != nil {
+			w.Abort()
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		matched, err := w.WriteGoogleContacts(*account, contacts, filepath.Base(path))
+		if err != nil {
+			w.Abort()
+			return fmt.Errorf("error writing contacts from %s: %w", path, err)
+		}
+		slog.Info("imported contacts", "file", path, "contacts", len(contacts), "matched", matched)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish sqlite output: %w", err)
+	}
+	return nil
+}
+
+// parseGoogleContactsFile reads a Google CSV file if the name ends in .csv
+// and a vCard file otherwise.
+func parseGoogleContactsFile(path string) ([]GoogleContact, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return parseContactsCSV(f)
+	}
+	return parseContactsVCard(f)
+}
+
+func parseContactsVCard(r io.Reader) ([]GoogleContact, error) {
+	cards, err := parseVCards(r)
+	if err != nil {
+		return nil, err
+	}
+	var contacts []GoogleContact
+	for _, card := range cards {
+		c := GoogleContact{Name: strings.TrimSpace(card.Get("FN"))}
+		if c.Name == "" {
+			// N is family;given;additional;prefix;suffix.
+			n := strings.Split(card.Get("N"), ";")
+			for len(n) < 3 {
+				n = append(n, "")
+			}
+			c.Name = strings.Join(strings.Fields(n[1]+" "+n[2]+" "+n[0]), " ")
+		}
+		org, _, _ := strings.Cut(card.Get("ORG"), ";")
+		c.Organization = strings.TrimSpace(org)
+		for _, photo := range card.All("PHOTO") {
+			if err := c.setPhoto(photo); err != nil {
+				return nil, fmt.Errorf("photo of %s: %w", c.Name, err)
+			}
+		}
+		for _, tel := range card.All("TEL") {
+			label := card.GroupValue(tel, "X-ABLABEL")
+			if label == "" && len(tel.Params["TYPE"]) > 0 {
+				label = strings.ToLower(tel.Params["TYPE"][0])
+			}
+			c.addPhone(strings.TrimPrefix(tel.Value, "tel:"), label)
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, nil
+}
+
+// csvPhoneColumn matches the "Phone 1 - Value" columns of a Google CSV
+// export.
+var csvPhoneColumn = regexp.MustCompile(`^Phone (\d+) - Value$`)
+
+// parseContactsCSV reads a Google CSV export. Both the current columns
+// (First Name, Organization Name, Phone 1 - Label) and the older ones (Name,
+// Organization 1 - Name, Phone 1 - Type) are understood. A cell holding
+// several numbers separates them with " ::: ".
+func parseContactsCSV(r io.Reader) ([]GoogleContact, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.TrimPrefix(h, "\ufeff")] = i
+	}
+
+	var contacts []GoogleContact
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		field := func(names ...string) string {
+			for _, name := range names {
+				if i, ok := cols[name]; ok && i < len(rec) && strings.TrimSpace(rec[i]) != "" {
+					return strings.TrimSpace(rec[i])
+				}
+			}
+			return ""
+		}
+
+		c := GoogleContact{
+			Name:         field("Name"),
+			Organization: field("Organization Name", "Organization 1 - Name"),
+			PhotoURL:     field("Photo"),
+		}
+		if c.Name == "" {
+			c.Name = strings.Join(strings.Fields(field("First Name")+" "+field("Middle Name")+" "+field("Last Name")), " ")
+		}
+		if c.Name == "" {
+			c.Name = field("Nickname")
+		}
+		for _, h := range header {
+			m := csvPhoneColumn.FindStringSubmatch(h)
+			if m == nil {
+				continue
+			}
+			label := field("Phone "+m[1]+" - Label", "Phone "+m[1]+" - Type")
+			label = strings.TrimPrefix(label, "* ")
+			for _, number := range strings.Split(field(h), ":::") {
+				c.addPhone(number, label)
+			}
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, nil
+}
+
+// setPhoto records a PHOTO property: a link, a data: URI, or base64 data
+// with its TYPE, as vCard 3.0 embeds it.
+func (c *GoogleContact) setPhoto(p vcardProperty) error {
+	v := strings.TrimSpace(p.Value)
+	switch {
+	case strings.HasPrefix(v, "http:"), strings.HasPrefix(v, "https:"):
+		c.PhotoURL = v
+		return nil
+	case strings.HasPrefix(v, "data:"):
+		head, data, ok := strings.Cut(strings.TrimPrefix(v, "data:"), ",")
+		if !ok || !strings.HasSuffix(head, ";base64") {
+			return fmt.Errorf("unsupported data URI")
+		}
+		c.PhotoType = strings.TrimSuffix(head, ";base64")
+		v = data
+	default:
+		enc := p.Params["ENCODING"]
+		if len(enc) == 0 || (!strings.EqualFold(enc[0], "b") && !strings.EqualFold(enc[0], "base64")) {
+			return nil
+		}
+		c.PhotoType = "image/jpeg"
+		if t := p.Params["TYPE"]; len(t) > 0 {
+			c.PhotoType = "image/" + strings.ToLower(t[0])
+		}
+	}
+	if !strings.HasPrefix(c.PhotoType, "image/") {
+		c.PhotoType = ""
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return err
+	}
+	c.PhotoData = data
+	return nil
+}
+
+func (c *GoogleContact) addPhone(number, label string) {
+	n := normalizeNumber(number)
+	if n == "" {
+		return
+	}
+	for _, p := range c.Phones {
+		if p.Number == n {
+			return
+		}
+	}
+	c.Phones = append(c.Phones, ContactPhone{Number: n, Label: label})
+}
+
+// WriteGoogleContacts matches contacts to the stored contacts of account,
+// or of every account if it is empty, and records their details. A stored
+// contact is matched by number, or by name if it has no number and only one
+// contact has that name. The account owner is left alone. It returns how
+// many stored contacts were matched.
+func (w *sqliteWriter) WriteGoogleContacts(account string, contacts []GoogleContact, sourceFile string) (int, error) {
+	byNumber := make(map[string]int)
+	byName := make(map[string][]int)
+	for i, c := range contacts {
+		for _, p := range c.Phones {
+			if _, ok := byNumber[p.Number]; !ok {
+				byNumber[p.Number] = i
+			}
+		}
+		if c.Name != "" {
+			key := strings.ToLower(c.Name)
+			byName[key] = append(byName[key], i)
+		}
+	}
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	type storedContact struct {
+		id           int64
+		name, number string
+	}
+	var stored []storedContact
+	rows, err := tx.Query(`SELECT id, COALESCE(name, ''), COALESCE(phone_number, '') FROM contact
+		WHERE (? = '' OR account = ?)
+			AND NOT EXISTS (SELECT 1 FROM participant WHERE participant.contact_id = contact.id AND participant.is_self)
+		ORDER BY id`, account, account)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query contacts: %w", err)
+	}
+	for rows.Next() {
+		var s storedContact
+		if err := rows.Scan(&s.id, &s.name, &s.number); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan contact row: %w", err)
+		}
+		stored = append(stored, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating contact rows: %w", err)
+	}
+
+	detailStmt, err := tx.Prepare(`INSERT INTO contact_detail (contact_id, display_name, organization, photo_url, photo_type, photo_data, source_file)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (contact_id) DO UPDATE SET display_name = excluded.display_name, organization = excluded.organization,
+			photo_url = excluded.photo_url, photo_type = excluded.photo_type, photo_data = excluded.photo_data,
+			source_file = excluded.source_file`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare contact detail statement: %w", err)
+	}
+	defer detailStmt.Close()
+	phoneStmt, err := tx.Prepare("INSERT OR IGNORE INTO contact_phone (contact_id, phone_number, label) VALUES (?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare contact phone statement: %w", err)
+	}
+	defer phoneStmt.Close()
+
+	matched := 0
+	for _, s := range stored {
+		i, ok := -1, false
+		if s.number != "" {
+			i, ok = byNumber[normalizeNumber(s.number)]
+		} else if m := byName[strings.ToLower(s.name)]; len(m) == 1 {
+			i, ok = m[0], true
+		}
+		if !ok {
+			continue
+		}
+		c := contacts[i]
+		if _, err := detailStmt.Exec(s.id, c.Name, c.Organization, c.PhotoURL, c.PhotoType, c.PhotoData, sourceFile); err != nil {
+			return 0, fmt.Errorf("failed to insert contact detail: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM contact_phone WHERE contact_id = ?", s.id); err != nil {
+			return 0, fmt.Errorf("failed to clear contact phones: %w", err)
+		}
+		for _, p := range c.Phones {
+			if p.Number == normalizeNumber(s.number) {
+				continue
+			}
+			if _, err := phoneStmt.Exec(s.id, p.Number, p.Label); err != nil {
+				return 0, fmt.Errorf("failed to insert contact phone: %w", err)
+			}
+		}
+		matched++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return matched, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGoogleContacts(t *testing.T) {
+	vcf, err := parseGoogleContactsFile(filepath.Join("testdata", "contacts.vcf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tony := GoogleContact{
+		Name:         "Tony Smehrik",
+		Organization: "Florida Hornet Removal",
+		PhotoURL:     "https://lh3.googleusercontent.com/contacts/tony",
+		Phones:       []ContactPhone{{Number: "+333", Label: "cell"}, {Number: "+15550100199", Label: "Work"}},
+	}
+	if len(vcf) != 4 || !reflect.DeepEqual(vcf[0], tony) {
+		t.Fatalf("Expected 4 cards starting with %+v, got %+v", tony, vcf)
+	}
+	if vcf[1].Name != "Sleve McDichael" {
+		t.Errorf("Expected a card without FN to be named from N, got %q", vcf[1].Name)
+	}
+	if vcf[2].PhotoType != "image/jpeg" || len(vcf[2].PhotoData) != 10 {
+		t.Errorf("Expected Sillio's embedded photo, got %q %x", vcf[2].PhotoType, vcf[2].PhotoData)
+	}
+
+	csv, err := parseGoogleContactsFile(filepath.Join("testdata", "contacts.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dwight := GoogleContact{
+		Name:         "Dwight Rortugal",
+		Organization: "Rortugal Logistics",
+		Phones:       []ContactPhone{{Number: "+66666", Label: "Mobile"}, {Number: "+15550100166", Label: "Mobile"}},
+	}
+	if len(csv) != 2 || !reflect.DeepEqual(csv[0], dwight) {
+		t.Errorf("Expected 2 rows starting with %+v, got %+v", dwight, csv)
+	}
+}
+
+func TestImportContacts(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "conversations.db")
+	w := &sqliteWriter{dbName: dbName}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, conv := range parseTestdata(t, "sms.html", "sms2.html", "voicemail.html", "recordedcall.html") {
+		conv.Account = "+2222"
+		if err := w.Write(conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runImportContacts([]string{"-db", dbName, filepath.Join("testdata", "contacts.vcf"), filepath.Join("testdata", "contacts.csv")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err = openReadOnly(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	displayLoc = time.UTC
+	defer func() { db.Close(); db, displayLoc = nil, nil }()
+
+	rows, err := db.Query(`SELECT contact.name, contact_display.name FROM contact
+		JOIN contact_display ON contact_display.id = contact.id ORDER BY contact.name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	names := make(map[string]string)
+	for rows.Next() {
+		var raw, display string
+		if err := rows.Scan(&raw, &display); err != nil {
+			t.Fatal(err)
+		}
+		names[raw] = display
+	}
+	want := map[string]string{
+		"Dwigt Rortugal":  "Dwight Rortugal",
+		"Me":              "Me",
+		"Sillio Sanford":  "Sillio Sanford",
+		"Sleve Mcdichael": "Sleve McDichael",
+		"Tony Smehrik":    "Tony Smehrik",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected display names %v, got %v", want, names)
+	}
+
+	phones, err := queryStrings(db, `SELECT contact.name || ' ' || contact_phone.phone_number || ' ' || contact_phone.label
+		FROM contact_phone JOIN contact ON contact.id = contact_phone.contact_id ORDER BY contact_phone.id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPhones := []string{
+		"Tony Smehrik +15550100199 Work",
+		"Sillio Sanford +15555550123 cell",
+		"Dwigt Rortugal +15550100166 Mobile",
+	}
+	if !reflect.DeepEqual(phones, wantPhones) {
+		t.Errorf("Expected extra numbers %v, got %v", wantPhones, phones)
+	}
+
+	groups, err := getGroups("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	people := make(map[string]storedParticipant)
+	for _, g := range groups {
+		for _, p := range g.Participants {
+			people[p.Name] = p
+		}
+	}
+	if p := people["Tony Smehrik"]; p.Organization != "Florida Hornet Removal" || p.HasPhoto {
+		t.Errorf("Expected Tony's organization and no stored photo, got %+v", p)
+	}
+	sillio, ok := people["Sillio Sanford"]
+	if !ok || !sillio.HasPhoto {
+		t.Fatalf("Expected Sillio Sanford with a photo, got %+v", people)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/contact/"+sillio.ContactUID+"/photo", nil)
+	req.SetPathValue("id", sillio.ContactUID)
+	contactPhotoHandler(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" || rec.Body.Len() != 10 {
+		t.Errorf("Expected the stored photo, got %d %q %d bytes", rec.Code, rec.Header().Get("Content-Type"), rec.Body.Len())
+	}
+}
+
+func TestImportContactsNeedsDatabase(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "typo.db")
+	err := runImportContacts([]string{"-db", dbName, filepath.Join("testdata", "contacts.vcf")})
+	if err == nil {
+		t.Fatal("Expected an error for a database that doesn't exist")
+	}
+	if _, err := os.Stat(dbName); !os.IsNotExist(err) {
+		t.Errorf("Expected no database to be created, got %v", err)
+	}
+
+	// A database from an older gvtakeout is refused too.
+	oldName := filepath.Join(t.TempDir(), "old.db")
+	old, err := sql.Open("sqlite", oldName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = old.Exec("CREATE TABLE contact (id INTEGER PRIMARY KEY, name TEXT, phone_number TEXT)")
+	old.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = runImportContacts([]string{"-db", oldName, filepath.Join("testdata", "contacts.vcf")})
+	if err == nil || !strings.Contains(err.Error(), "older version") {
+		t.Errorf("Expected an old database to be refused, got %v", err)
+	}
+}
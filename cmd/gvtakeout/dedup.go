@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// Google sometimes writes the same message into two thread files where
+// threads overlap, and a message can appear again in a later takeout. Each
+// message is stored with its stable ID, a hash of its account, sender
+// number, timestamp and content (see messageUID), which the message table
+// keeps unique. A message whose ID is already stored is recorded in
+// duplicate_message instead of being inserted again.
+//
+// A phone and Google Voice timestamp the same message differently, so a
+// message from another origin (see Conversation.Origin) is also a duplicate
+// of one in the same thread, from the same sender, with the same content,
+// within nearDuplicateWindow of it.
+
+// nearDuplicateWindow is how far apart two origins may timestamp the same
+// message.
+const nearDuplicateWindow = time.Minute
+
+// findDuplicates returns, by index, the ids of conv's messages that are
+// already stored.
+func findDuplicates(tx *sql.Tx, conv Conversation) (map[int]int64, error) {
+	dups := make(map[int]int64)
+	matched := make(map[int64]bool)
+	for i, m := range conv.Messages {
+		var id int64
+		err := tx.QueryRow("SELECT id FROM message WHERE uid = ?", m.ID).Scan(&id)
+		if err == sql.ErrNoRows {
+			id, err = findNearDuplicate(tx, conv, m, matched)
+		}
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to look up message: %w", err)
+		}
+		dups[i] = id
+		matched[id] = true
+	}
+	return dups, nil
+}
+
+// findNearDuplicate returns the id of the closest stored message from
+// another origin that m repeats, or sql.ErrNoRows. Messages in matched have
+// already been repeated by another message of conv.
+func findNearDuplicate(tx *sql.Tx, conv Conversation, m Message, matched map[int64]bool) (int64, error) {
+	p := conv.Participants.find(m.Sender, m.SenderNumber)
+	if p < 0 {
+		return 0, sql.ErrNoRows
+	}
+	ms := m.Timestamp.UnixMilli()
+	window := nearDuplicateWindow.Milliseconds()
+	rows, err := tx.Query(`SELECT message.id FROM message
+		JOIN conversation ON conversation.id = message.conversation_id
+		JOIN contact ON contact.id = message.sender_contact_id
+		WHERE message.timestamp_ms BETWEEN ? AND ?
+			AND conversation.thread_uid = ? AND conversation.origin != ?
+			AND contact.uid = ? AND message.content = ?
+		ORDER BY ABS(message.timestamp_ms - ?), message.id`,
+		ms-window, ms+window, conv.ThreadID, conv.Origin, conv.Participants[p].ContactID, m.Content, ms)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		if !matched[id] {
+			return id, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return 0, sql.ErrNoRows
+}
+
+// recordDuplicates notes the stored messages that sourceFile repeated and
+// returns how many it hadn't already noted.
+func recordDuplicates(tx *sql.Tx, sourceFile string, dups []int64) (int, error) {
+	if len(dups) == 0 {
+		return 0, nil
+	}
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO duplicate_message (message_id, source_file) VALUES (?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare duplicate statement: %w", err)
+	}
+	defer stmt.Close()
+	n := 0
+	for _, id := range dups {
+		result, err := stmt.Exec(id, sourceFile)
+		if err != nil {
+			return 0, fmt.Errorf("failed to record duplicate message: %w", err)
+		}
+		added, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to record duplicate message: %w", err)
+		}
+		n += int(added)
+	}
+	return n, nil
+}
+
+// reportDuplicates logs how many duplicate messages were dropped from each
+// thread file.
+func reportDuplicates(dups map[string]int) {
+	files := make([]string, 0, len(dups))
+	total := 0
+	for f, n := range dups {
+		files = append(files, f)
+		total += n
+	}
+	if total == 0 {
+		return
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		slog.Info("removed duplicate messages", "file", f, "count", dups[f])
+	}
+	slog.Info("duplicate messages removed", "total", total, "files", len(files))
+}
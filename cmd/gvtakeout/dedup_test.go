@@ -0,0 +1,121 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDuplicateMessages(t *testing.T) {
+	sms := parseTestdata(t, "sms.html")[0]
+	if len(sms.Messages) < 2 {
+		t.Fatalf("Expected sms.html to have several messages, got %d", len(sms.Messages))
+	}
+
+	again := sms
+	again.SourceFile = "sms-again.html"
+
+	overlap := sms
+	overlap.SourceFile = "sms-overlap.html"
+	overlap.Timestamp = sms.Messages[len(sms.Messages)-1].Timestamp
+	extra := sms.Messages[len(sms.Messages)-1]
+	extra.Timestamp = extra.Timestamp.Add(1)
+	extra.Content = "one more thing"
+	overlap.Messages = []Message{sms.Messages[len(sms.Messages)-1], extra}
+
+	// Importing a file again records its duplicates only once.
+	db := importConversations(t, []Conversation{sms, again, again, overlap})
+
+	var convs, msgs int
+	if err := db.QueryRow("SELECT COUNT(*) FROM conversation").Scan(&convs); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM message").Scan(&msgs); err != nil {
+		t.Fatal(err)
+	}
+	// The overlapping file is the same thread, so its new message joins
+	// the stored conversation.
+	if convs != 1 || msgs != len(sms.Messages)+1 {
+		t.Errorf("Expected 1 conversation and %d messages, got %d and %d", len(sms.Messages)+1, convs, msgs)
+	}
+
+	got := make(map[string]int)
+	rows, err := db.Query("SELECT source_file, COUNT(*) FROM duplicate_message GROUP BY source_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			file string
+			n    int
+		)
+		if err := rows.Scan(&file, &n); err != nil {
+			t.Fatal(err)
+		}
+		got[file] = n
+	}
+	if got["sms-again.html"] != len(sms.Messages) || got["sms-overlap.html"] != 1 || len(got) != 2 {
+		t.Errorf("Expected duplicates from sms-again.html and sms-overlap.html, got %v", got)
+	}
+
+	st, err := collectStats(db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Duplicates != len(sms.Messages)+1 {
+		t.Errorf("Expected %d duplicates in stats, got %d", len(sms.Messages)+1, st.Duplicates)
+	}
+}
+
+func TestDuplicateCount(t *testing.T) {
+	sms := parseTestdata(t, "sms.html")[0]
+	w := &sqliteWriter{dbName: filepath.Join(t.TempDir(), "conversations.db")}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	again := sms
+	again.SourceFile = "sms-again.html"
+	for i, want := range []int{0, len(sms.Messages), 0} {
+		conv := sms
+		if i > 0 {
+			conv = again
+		}
+		n, err := insertConversation(w.db, conv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("Import %d: Expected %d new duplicates, got %d", i+1, want, n)
+		}
+	}
+}
+
+func TestReactionsOnStoredMessages(t *testing.T) {
+	withReactions := parseTestdata(t, "reactions.html")[0]
+
+	// An earlier takeout without the reactions, then the later one twice.
+	without := withReactions
+	without.SourceFile = "earlier.html"
+	without.Messages = make([]Message, len(withReactions.Messages))
+	want := 0
+	for i, m := range withReactions.Messages {
+		want += len(m.Reactions)
+		m.Reactions = nil
+		without.Messages[i] = m
+	}
+	if want == 0 {
+		t.Fatal("Expected reactions in reactions.html")
+	}
+
+	db := importConversations(t, []Conversation{without, withReactions, withReactions})
+
+	var got int
+	if err := db.QueryRow("SELECT COUNT(*) FROM reaction").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Expected the later takeout's %d reactions stored once, got %d", want, got)
+	}
+}
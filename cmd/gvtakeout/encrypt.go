@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"filippo.io/age"
+	"golang.org/x/term"
+)
+
+// passphraseEnv, if set, supplies the passphrase instead of prompting.
+const passphraseEnv = "GVTAKEOUT_PASSPHRASE"
+
+// Encryption holds the age recipients output is encrypted to, and the
+// identities used to reopen an existing encrypted database.
+type Encryption struct {
+	Recipients []age.Recipient
+	Identities []age.Identity
+}
+
+// encryptFlags are the -encrypt flags of the parse and import commands.
+type encryptFlags struct {
+	encrypt        bool
+	recipients     string
+	recipientsFile string
+}
+
+func (f *encryptFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.encrypt, "encrypt", false, "Encrypt the output with age, using a passphrase unless -recipient or -recipients-file is set")
+	fs.StringVar(&f.recipients, "recipient", "", "Comma separated age recipients (age1...) to encrypt to")
+	fs.StringVar(&f.recipientsFile, "recipients-file", "", "File of age recipients to encrypt to")
+}
+
+// load builds the Encryption for the flags, or returns nil if encryption
+// wasn't requested. Without recipients it falls back to a passphrase.
+// identityFile is used to reopen an existing encrypted database.
+func (f *encryptFlags) load(identityFile string) (*Encryption, error) {
+	if !f.encrypt {
+		if f.recipients != "" || f.recipientsFile != "" {
+			return nil, errors.New("-recipient and -recipients-file require -encrypt")
+		}
+		return nil, nil
+	}
+
+	var enc Encryption
+	for _, s := range splitList(f.recipients) {
+		r, err := age.ParseX25519Recipient(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -recipient %q: %w", s, err)
+		}
+		enc.Recipients = append(enc.Recipients, r)
+	}
+
+	if f.recipientsFile != "" {
+		file, err := os.Open(f.recipientsFile)
+		if err != nil {
+			return nil, err
+		}
+		rs, err := age.ParseRecipients(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", f.recipientsFile, err)
+		}
+		enc.Recipients = append(enc.Recipients, rs...)
+	}
+
+	if identityFile != "" {
+		ids, err := parseIdentityFile(identityFile)
+		if err != nil {
+			return nil, err
+		}
+		enc.Identities = ids
+	}
+
+	if len(enc.Recipients) > 0 {
+		return &enc, nil
+	}
+
+	pass, err := readPassphrase(true)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.NewScryptRecipient(pass)
+	if err != nil {
+		return nil, err
+	}
+	id, err := age.NewScryptIdentity(pass)
+	if err != nil {
+		return nil, err
+	}
+	enc.Recipients = []age.Recipient{r}
+	enc.Identities = append(enc.Identities, id)
+	return &enc, nil
+}
+
+func parseIdentityFile(name string) ([]age.Identity, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ids, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", name, err)
+	}
+	return ids, nil
+}
+
+// readPassphrase reads the passphrase from $GVTAKEOUT_PASSPHRASE or prompts
+// on the terminal, asking twice if confirm is set.
+func readPassphrase(confirm bool) (string, error) {
+	if pass := os.Getenv(passphraseEnv); pass != "" {
+		return pass, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no passphrase: set %s or run from a terminal", passphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	pass, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(pass) == 0 {
+		return "", errors.New("empty passphrase")
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(pass) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+
+	return string(pass), nil
+}
+
+// encryptFile writes an age encrypted copy of src to dst. dst is replaced
+// atomically so a failed run never leaves a truncated archive behind.
+func encryptFile(src, dst string, recipients []age.Recipient) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	w, err := age.Encrypt(out, recipients...)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
+
+// decryptFile writes the plaintext of the age file src to dst, readable only
+// by the current user.
+func decryptFile(src, dst string, identities []age.Identity) error {
+	if len(identities) == 0 {
+		return errors.New("no identity to decrypt with; pass -identity")
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r, err := age.Decrypt(in, identities...)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// decryptDB decrypts an age encrypted database into a private temporary
+// directory and returns the plaintext path. The returned function removes
+// the copy; it is also removed on SIGINT or SIGTERM.
+func decryptDB(path, identityFile string) (string, func(), error) {
+	var identities []age.Identity
+	if identityFile != "" {
+		ids, err := parseIdentityFile(identityFile)
+		if err != nil {
+			return "", nil, err
+		}
+		identities = ids
+	} else {
+		pass, err := readPassphrase(false)
+		if err != nil {
+			return "", nil, err
+		}
+		id, err := age.NewScryptIdentity(pass)
+		if err != nil {
+			return "", nil, err
+		}
+		identities = []age.Identity{id}
+	}
+
+	dir, err := os.MkdirTemp("", "gvtakeout-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-sigs; ok {
+			os.RemoveAll(dir)
+			os.Exit(1)
+		}
+	}()
+	cleanup := func() {
+		signal.Stop(sigs)
+		close(sigs)
+		os.RemoveAll(dir)
+	}
+
+	plain := filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), ".age"))
+	if err := decryptFile(path, plain, identities); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return plain, cleanup, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+)
+
+func TestSQLiteWriterEncrypted(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := &Encryption{
+		Recipients: []age.Recipient{id.Recipient()},
+		Identities: []age.Identity{id},
+	}
+
+	dir := t.TempDir()
+	dbName := filepath.Join(dir, "conversations.db")
+
+	// Two runs: the second has to reopen the archive written by the first.
+	for i, name := range []string{"voicemail.html", "sms.html"} {
+		w := &sqliteWriter{dbName: dbName, enc: enc}
+		if err := w.Begin(); err != nil {
+			t.Fatal(err)
+		}
+		for _, conv := range parseTestdata(t, name) {
+			if err := w.Write(conv); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close run %d: %v", i, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "conversations.db.age" {
+		t.Fatalf("Expected only conversations.db.age, got %v", entries)
+	}
+
+	plain := filepath.Join(t.TempDir(), "plain.db")
+	if err := decryptFile(dbName+".age", plain, enc.Identities); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var got int
+	if err := db.QueryRow("SELECT COUNT(*) FROM conversation").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != 2 {
+		t.Errorf("Expected 2 conversations, got %d", got)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decryptFile(dbName+".age", plain, []age.Identity{other}); err == nil {
+		t.Error("Expected decrypting with the wrong identity to fail")
+	}
+}
+
+func TestSQLiteWriterAbort(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := &Encryption{
+		Recipients: []age.Recipient{id.Recipient()},
+		Identities: []age.Identity{id},
+	}
+
+	dir := t.TempDir()
+	dbName := filepath.Join(dir, "conversations.db")
+	w := &sqliteWriter{dbName: dbName, enc: enc}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(dbName + ".age")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed run leaves the archive as it was and removes its copy.
+	w = &sqliteWriter{dbName: dbName, enc: enc}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	tmpDir := w.tmpDir
+	for _, conv := range parseTestdata(t, "sms.html") {
+		if err := w.Write(conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	abortOutput(w)
+
+	after, err := os.ReadFile(dbName + ".age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("Expected an aborted run to leave the encrypted database unchanged")
+	}
+	if _, err := os.Stat(tmpDir); !os.IsNotExist(err) {
+		t.Errorf("Expected the decrypted copy in %s removed, got %v", tmpDir, err)
+	}
+}
+
+func TestJSONWriterEncrypted(t *testing.T) {
+	id, err := age.NewScryptIdentity("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := age.NewScryptRecipient("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keep the test fast; the default work factor takes about a second.
+	r.SetWorkFactor(10)
+
+	var buf bytes.Buffer
+	w, err := outputWriters["json"](OutputOptions{
+		Stdout:     &buf,
+		Location:   time.UTC,
+		Encryption: &Encryption{Recipients: []age.Recipient{r}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, conv := range parseTestdata(t, "voicemail.html") {
+		if err := w.Write(conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(buf.Bytes(), []byte("voicemail")) {
+		t.Fatal("Expected ciphertext, found plaintext")
+	}
+
+	pr, err := age.Decrypt(&buf, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plain bytes.Buffer
+	if _, err := plain.ReadFrom(pr); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(plain.Bytes(), []byte(`"type":"voicemail"`)) {
+		t.Errorf("Expected decrypted voicemail, got %s", plain.String())
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+func runExport(args []string) error {
+	fs, common := newFlagSet("export", "> conversations.json")
+	account := fs.String("account", "", "Only export conversations from this account")
+	legacyParticipants := fs.Bool("legacy-participants", false, `Write participants in the old {"name": "number"} form`)
+	fs.Parse(args)
+
+	loc, err := common.setup()
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := common.openDB()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	w, err := outputWriters["json"](OutputOptions{Stdout: os.Stdout, Location: loc, LegacyParticipants: *legacyParticipants})
+	if err != nil {
+		return err
+	}
+	if err := w.Begin(); err != nil {
+		return err
+	}
+	if err := exportConversations(db, *account, w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// exportConversations reads conversations back out of the database in the
+// shape the parser produced them, oldest first. Labels aren't stored in the
+// database so they are omitted.
+func exportConversations(db *sql.DB, account string, w OutputWriter) error {
+	rows, err := db.Query(`SELECT id, uid, thread_uid, account, type, timestamp_ms, utc_offset, duration, transcript, source_file, origin
+		FROM conversation
+		WHERE (? = '' OR account = ?)
+		ORDER BY timestamp_ms, id`, account, account)
+	if err != nil {
+		return fmt.Errorf("failed to query conversations: %w", err)
+	}
+
+	type storedConv struct {
+		id   int64
+		conv Conversation
+	}
+	var convs []storedConv
+	for rows.Next() {
+		var (
+			c      storedConv
+			ms     sql.NullInt64
+			offset sql.NullInt64
+		)
+		err := rows.Scan(&c.id, &c.conv.ID, &c.conv.ThreadID, &c.conv.Account, &c.conv.Type, &ms, &offset, &c.conv.Duration, &c.conv.Transcript, &c.conv.SourceFile, &c.conv.Origin)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan conversation row: %w", err)
+		}
+		c.conv.Timestamp = storedTime(ms, offset)
+		convs = append(convs, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating conversation rows: %w", err)
+	}
+
+	for _, c := range convs {
+		conv := c.conv
+		if err := loadConversationDetails(db, c.id, &conv); err != nil {
+			return fmt.Errorf("conversation %d: %w", c.id, err)
+		}
+		if err := w.Write(conv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conversationIDForUID returns the database id of the conversation with the
+// stable ID uid.
+func conversationIDForUID(db *sql.DB, uid string) (int64, error) {
+	var id int64
+	err := db.QueryRow("SELECT id FROM conversation WHERE uid = ?", uid).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("no conversation %s", uid)
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to query conversation: %w", err)
+	}
+	return id, nil
+}
+
+// loadConversation reads the stored conversation with the given id.
+func loadConversation(db *sql.DB, id int64) (Conversation, error) {
+	var (
+		conv       Conversation
+		ms, offset sql.NullInt64
+	)
+	err := db.QueryRow(`SELECT uid, thread_uid, account, type, timestamp_ms, utc_offset, duration, transcript, source_file, origin
+		FROM conversation WHERE id = ?`, id).Scan(&conv.ID, &conv.ThreadID, &conv.Account, &conv.Type, &ms, &offset, &conv.Duration, &conv.Transcript, &conv.SourceFile, &conv.Origin)
+	if err == sql.ErrNoRows {
+		return conv, fmt.Errorf("no conversation #%d", id)
+	} else if err != nil {
+		return conv, fmt.Errorf("failed to query conversation: %w", err)
+	}
+	conv.Timestamp = storedTime(ms, offset)
+	if err := loadConversationDetails(db, id, &conv); err != nil {
+		return conv, fmt.Errorf("conversation %d: %w", id, err)
+	}
+	return conv, nil
+}
+
+// loadConversationDetails fills in the participants, messages, attachments,
+// reactions and call event of a stored conversation.
+func loadConversationDetails(db *sql.DB, convID int64, conv *Conversation) error {
+	conv.Participants = nil
+	rows, err := db.Query(`SELECT contact.uid, contact.name, contact.phone_number, participant.is_self, participant.source
+		FROM participant JOIN contact ON contact.id = participant.contact_id
+		WHERE participant.conversation_id = ?
+		ORDER BY participant.id`, convID)
+	if err != nil {
+		return fmt.Errorf("failed to query participants: %w", err)
+	}
+	for rows.Next() {
+		var p Participant
+		if err := rows.Scan(&p.ContactID, &p.Name, &p.Number, &p.IsSelf, &p.Source); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan participant row: %w", err)
+		}
+		conv.Participants = append(conv.Participants, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating participant rows: %w", err)
+	}
+
+	rows, err = db.Query(`SELECT m.id, m.uid, m.timestamp_ms, m.utc_offset, COALESCE(c.name, ''), COALESCE(c.phone_number, ''), m.content
+		FROM message m LEFT JOIN contact c ON c.id = m.sender_contact_id
+		WHERE m.conversation_id = ?
+		ORDER BY m.timestamp_ms, m.id`, convID)
+	if err != nil {
+		return fmt.Errorf("failed to query messages: %w", err)
+	}
+	msgIndex := make(map[int64]int)
+	for rows.Next() {
+		var (
+			id         int64
+			ms, offset sql.NullInt64
+			m          Message
+		)
+		if err := rows.Scan(&id, &m.ID, &ms, &offset, &m.Sender, &m.SenderNumber, &m.Content); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan message row: %w", err)
+		}
+		m.Timestamp = storedTime(ms, offset)
+		msgIndex[id] = len(conv.Messages)
+		conv.Messages = append(conv.Messages, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating message rows: %w", err)
+	}
+
+	conv.CallEvent, err = loadCallEvent(db, convID)
+	if err != nil {
+		return err
+	}
+
+	err = db.QueryRow("SELECT audio_url FROM call_audio WHERE conversation_id = ? ORDER BY id LIMIT 1", convID).Scan(&conv.Audio)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to query call audio: %w", err)
+	}
+
+	if len(conv.Messages) == 0 {
+		return nil
+	}
+
+	rows, err = db.Query(`SELECT image.message_id, image.image_url
+		FROM image JOIN message ON message.id = image.message_id
+		WHERE message.conversation_id = ?
+		ORDER BY image.id`, convID)
+	if err != nil {
+		return fmt.Errorf("failed to query images: %w", err)
+	}
+	for rows.Next() {
+		var (
+			msgID int64
+			url   string
+		)
+		if err := rows.Scan(&msgID, &url); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan image row: %w", err)
+		}
+		if i, ok := msgIndex[msgID]; ok {
+			conv.Messages[i].Images = append(conv.Messages[i].Images, url)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating image rows: %w", err)
+	}
+
+	rows, err = db.Query(`SELECT r.message_id, r.timestamp_ms, r.utc_offset, COALESCE(c.name, ''), COALESCE(c.phone_number, ''), r.kind
+		FROM reaction r
+		JOIN message m ON m.id = r.message_id
+		LEFT JOIN contact c ON c.id = r.sender_contact_id
+		WHERE m.conversation_id = ?
+		ORDER BY r.id`, convID)
+	if err != nil {
+		return fmt.Errorf("failed to query reactions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			msgID      int64
+			ms, offset sql.NullInt64
+			r          Reaction
+		)
+		if err := rows.Scan(&msgID, &ms, &offset, &r.Sender, &r.SenderNumber, &r.Kind); err != nil {
+			return fmt.Errorf("failed to scan reaction row: %w", err)
+		}
+		r.Timestamp = storedTime(ms, offset)
+		if i, ok := msgIndex[msgID]; ok {
+			conv.Messages[i].Reactions = append(conv.Messages[i].Reactions, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating reaction rows: %w", err)
+	}
+	return nil
+}
+
+// storedTime reverses timestampColumns, returning the zero time for NULLs.
+func storedTime(ms, offset sql.NullInt64) time.Time {
+	if !ms.Valid {
+		return time.Time{}
+	}
+	return localTime(ms.Int64, int(offset.Int64), nil)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// importTestdata writes the named testdata files to a new database and
+// reopens it read-only, as the database commands do.
+func importTestdata(t *testing.T, names ...string) *sql.DB {
+	t.Helper()
+	return importConversations(t, parseTestdata(t, names...))
+}
+
+// importConversations writes convs to a new database under the account
+// "+2222" and reopens it read-only.
+func importConversations(t *testing.T, convs []Conversation) *sql.DB {
+	t.Helper()
+
+	dbName := filepath.Join(t.TempDir(), "conversations.db")
+	w := &sqliteWriter{dbName: dbName}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, conv := range convs {
+		conv.Account = "+2222"
+		if err := w.Write(conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := openReadOnly(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	// Each file is a thread of its own; files of one thread would be
+	// exported as the one conversation they are stored as.
+	names := []string{"missedcall.html", "voicemail.html", "reactions.html", "mms.html"}
+	db := importTestdata(t, names...)
+
+	var got bytes.Buffer
+	w, err := outputWriters["json"](OutputOptions{Stdout: &got})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exportConversations(db, "", w); err != nil {
+		t.Fatal(err)
+	}
+
+	// The database doesn't keep labels; otherwise export reproduces the
+	// parser's output, oldest conversation first.
+	var want bytes.Buffer
+	enc := json.NewEncoder(&want)
+	for _, conv := range parseTestdata(t, names...) {
+		conv.Account = "+2222"
+		conv.Labels = nil
+		conv.assignIDs()
+		if err := enc.Encode(conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got.String() != want.String() {
+		t.Errorf("Export mismatch\nExpected:\n%s\nGot:\n%s", want.String(), got.String())
+	}
+
+	got.Reset()
+	if err := exportConversations(db, "someone else", w); err != nil {
+		t.Fatal(err)
+	}
+	if got.Len() != 0 {
+		t.Errorf("Expected no conversations for another account, got %s", got.String())
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	db := importTestdata(t, "voicemail.html", "mms.html")
+
+	results, err := searchMessages(db, "HAHA", "", 0, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d: %+v", len(results), results)
+	}
+	if results[0].Sender != "Tony Smehrik" || !results[0].Timestamp.After(results[1].Timestamp) {
+		t.Errorf("Expected newest result from Tony Smehrik first, got %+v", results)
+	}
+
+	results, err = searchMessages(db, "quick questions", "", 0, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Transcript || results[0].Sender != "Sleve Mcdichael" {
+		t.Errorf("Expected the voicemail transcript, got %+v", results)
+	}
+
+	// LIKE wildcards in the query are literal.
+	results, err = searchMessages(db, "%", "", 0, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results for %%, got %+v", results)
+	}
+}
+
+func TestStats(t *testing.T) {
+	db := importTestdata(t, "voicemail.html", "sms.html", "mms.html")
+
+	st, err := collectStats(db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Conversations != 3 || st.Types["chat"] != 2 || st.Messages != 11 {
+		t.Errorf("Expected 3 conversations, 2 chats, 11 messages, got %d, %d, %d", st.Conversations, st.Types["chat"], st.Messages)
+	}
+	if len(st.TopContacts) == 0 || st.TopContacts[0].Name != "Tony Smehrik" {
+		t.Errorf("Expected Tony Smehrik as top contact, got %+v", st.TopContacts)
+	}
+
+	var buf bytes.Buffer
+	if err := st.print(&buf, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Activity:") {
+		t.Errorf("Expected activity range in output, got %s", buf.String())
+	}
+}
+
+func TestVerifyDB(t *testing.T) {
+	db := importTestdata(t, "voicemail.html", "mms.html")
+
+	problems, warnings, err := verifyDB(db, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "images without a media file") {
+		t.Errorf("Expected a missing media warning, got %v", warnings)
+	}
+
+	want := make(map[string]bool)
+	for _, f := range []string{"missedcall.html", "missedcall-voicemail.html", "reactions.html", "recordedcall.html", "sms.html", "sms2.html", "unknowncall.html", "voicemail-notranscript.html"} {
+		want["not imported: "+f] = true
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("Expected %d problems, got %v", len(want), problems)
+	}
+	for _, p := range problems {
+		if !want[p] {
+			t.Errorf("Unexpected problem %q", p)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Conversations from outside the Voice takeout, such as an Android phone's
+// backup or a Hangouts takeout, are imported into an existing database by
+// the import-* commands. Each sets its conversations' Origin, names the
+// contacts the database already knows as the database does so the threads
+// line up with Voice's, and relies on near-duplicate detection (see
+// findNearDuplicate) to drop the messages Voice already has.
+
+// externalParser reads the conversations from one file or directory given
+// to an import-* command.
+type externalParser func(path, account string, known knownContacts, loc *time.Location) ([]Conversation, error)
+
+// runExternalImport runs an import-* command, parsing each argument with
+// parse and writing the conversations to -db.
+func runExternalImport(name, usage string, args []string, parse externalParser) error {
+	fs, common := newFlagSet(name, usage)
+	account := fs.String("account", "", "Account to import into (default: the only account in the database)")
+	var encFlags encryptFlags
+	encFlags.register(fs)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("nothing to import")
+	}
+
+	loc, err := common.setup()
+	if err != nil {
+		return err
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+
+	w, err := openImportWriter(common, encFlags)
+	if err != nil {
+		return err
+	}
+
+	acct := *account
+	if acct == "" {
+		acct, err = defaultAccount(w.db)
+		if err != nil {
+			w.Abort()
+			return err
+		}
+	}
+	known, err := loadKnownContacts(w.db, acct)
+	if err != nil {
+		w.Abort()
+		return err
+	}
+
+	for _, path := range fs.Args() {
+		convs, err := parse(path, acct, known, loc)
+		if err != nil {
+			w.Abort()
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for _, conv := range convs {
+			if err := w.Write(conv); err != nil {
+				w.Abort()
+				return fmt.Errorf("error writing conversation from %s: %w", path, err)
+			}
+		}
+		slog.Info("imported conversations", "file", path, "conversations", len(convs))
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish sqlite output: %w", err)
+	}
+	return nil
+}
+
+// openImportWriter opens -db for an import-* command to add to, decrypting
+// it first if it is encrypted.
+func openImportWriter(common *commonFlags, encFlags encryptFlags) (*sqliteWriter, error) {
+	if strings.HasSuffix(common.db, ".age") {
+		encFlags.encrypt = true
+	}
+	enc, err := encFlags.load(common.identity)
+	if err != nil {
+		return nil, fmt.Errorf("encryption setup failed: %w", err)
+	}
+
+	w := &sqliteWriter{dbName: strings.TrimSuffix(common.db, ".age"), enc: enc}
+	if err := w.Begin(); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return w, nil
+}
+
+// defaultAccount returns the only named account in the database, or "" if
+// there is none.
+func defaultAccount(db *sql.DB) (string, error) {
+	accounts, err := queryStrings(db, "SELECT DISTINCT account FROM conversation WHERE account != '' ORDER BY account")
+	if err != nil {
+		return "", err
+	}
+	switch len(accounts) {
+	case 0:
+		return "", nil
+	case 1:
+		slog.Info("importing into the database's account", "account", accounts[0])
+		return accounts[0], nil
+	}
+	return "", fmt.Errorf("the database has several accounts (%s); choose one with -account", strings.Join(accounts, ", "))
+}
+
+// knownContacts are the contacts already stored for an account, by
+// normalized number, so a phone's contacts take the names and numbers they
+// have in Voice and its threads line up with Voice's.
+type knownContacts struct {
+	self    Participant
+	numbers map[string]Participant
+}
+
+func loadKnownContacts(db *sql.DB, account string) (knownContacts, error) {
+	k := knownContacts{
+		self:    Participant{Name: selfName, Number: account, IsSelf: true},
+		numbers: make(map[string]Participant),
+	}
+	if !looksLikePhoneNumber(account) {
+		k.self.Number = ""
+	}
+
+	rows, err := db.Query(`SELECT contact.name, contact.phone_number,
+		EXISTS (SELECT 1 FROM participant WHERE participant.contact_id = contact.id AND participant.is_self)
+		FROM contact
+		WHERE contact.account = ? AND contact.phone_number != ''
+		ORDER BY contact.id`, account)
+	if err != nil {
+		return k, fmt.Errorf("failed to query contacts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			p      Participant
+			isSelf bool
+		)
+		if err := rows.Scan(&p.Name, &p.Number, &isSelf); err != nil {
+			return k, fmt.Errorf("failed to scan contact row: %w", err)
+		}
+		if isSelf {
+			k.self.Number = p.Number
+			continue
+		}
+		k.numbers[normalizeNumber(p.Number)] = p
+	}
+	if err := rows.Err(); err != nil {
+		return k, fmt.Errorf("error iterating contact rows: %w", err)
+	}
+	return k, nil
+}
+
+// me returns the account owner as a participant found in source.
+func (k knownContacts) me(source string) Participant {
+	p := k.self
+	p.Source = source
+	return p
+}
+
+// contact returns the participant for a number from the backup, named as
+// the database already names it, else as the phone does, else by number.
+func (k knownContacts) contact(number, name, source string) Participant {
+	n := normalizeNumber(number)
+	if p, ok := k.numbers[n]; ok {
+		return Participant{Name: p.Name, Number: p.Number, Source: source}
+	}
+	if name == "" || name == "(Unknown)" || name == "null" {
+		name = n
+	}
+	if name == "" {
+		name = "Unknown"
+	}
+	return Participant{Name: name, Number: n, Source: source}
+}
+
+// normalizeNumber puts a number from a phone in the form Voice uses, e.g.
+// +15551234567. Google Voice numbers are North American, so ten digits
+// without a country code are taken to be in +1.
+func normalizeNumber(s string) string {
+	digits := digitsOnly(s)
+	switch {
+	case digits == "":
+		return ""
+	case strings.HasPrefix(strings.TrimSpace(s), "+"):
+		return "+" + digits
+	case len(digits) == 10:
+		return "+1" + digits
+	case len(digits) == 11 && digits[0] == '1':
+		return "+" + digits
+	}
+	return digits
+}
+
+// finishChat orders a chat assembled from an external source by time,
+// starts it at its first message, and folds in its reactions.
+func finishChat(c *Conversation) {
+	sort.SliceStable(c.Messages, func(i, j int) bool {
+		return c.Messages[i].Timestamp.Before(c.Messages[j].Timestamp)
+	})
+	if len(c.Messages) > 0 {
+		c.Timestamp = c.Messages[0].Timestamp
+	}
+	c.linkReactions()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var knownTypes = []string{"chat", "voicemail", "missed_call", "placed_call", "received_call", "recorded_call", "unknown_call"}
+
+// conversationFilter selects which parsed conversations are written. It runs
+// after parseFile so every output format sees the same set of conversations.
+type conversationFilter struct {
+	since         time.Time
+	until         time.Time
+	types         map[string]bool
+	participant   string
+	excludeLabels []string
+}
+
+// filterFlags are the filter flags of the parse and import commands.
+type filterFlags struct {
+	since         string
+	until         string
+	types         string
+	participant   string
+	excludeLabels string
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.since, "since", "", "Only include activity at or after this time (YYYY-MM-DD or RFC3339)")
+	fs.StringVar(&f.until, "until", "", "Only include activity before this time (YYYY-MM-DD is inclusive of that day, or RFC3339)")
+	fs.StringVar(&f.types, "type", "", "Comma separated conversation types to include ("+strings.Join(knownTypes, ",")+")")
+	fs.StringVar(&f.participant, "participant", "", "Only include conversations with a participant matching this name or number")
+	fs.StringVar(&f.excludeLabels, "exclude-label", "", "Comma separated takeout labels to exclude (e.g. Spam)")
+}
+
+// newConversationFilter builds a filter from the command line flags. Date only
+// values for -since and -until are interpreted in loc, or the local zone if
+// loc is nil.
+func newConversationFilter(flags filterFlags, loc *time.Location) (*conversationFilter, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	var (
+		f   conversationFilter
+		err error
+	)
+
+	if flags.since != "" {
+		f.since, err = parseFilterTime(flags.since, loc, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -since: %w", err)
+		}
+	}
+	if flags.until != "" {
+		f.until, err = parseFilterTime(flags.until, loc, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -until: %w", err)
+		}
+	}
+
+	for _, t := range splitList(flags.types) {
+		if !isKnownType(t) {
+			return nil, fmt.Errorf("invalid -type %q, must be one of %s", t, strings.Join(knownTypes, ","))
+		}
+		if f.types == nil {
+			f.types = make(map[string]bool)
+		}
+		f.types[t] = true
+	}
+
+	f.participant = strings.TrimSpace(flags.participant)
+	f.excludeLabels = splitList(flags.excludeLabels)
+
+	return &f, nil
+}
+
+// parseFilterTime accepts either RFC3339 or a bare YYYY-MM-DD date. When
+// endOfDay is set a bare date refers to the end of that day so that
+// -until=2024-01-31 includes all of January 31st.
+func parseFilterTime(s string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not YYYY-MM-DD or RFC3339", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func isKnownType(t string) bool {
+	for _, known := range knownTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// apply reports whether conv should be written. Chat messages outside the
+// -since/-until window are dropped, and the conversation is skipped if none
+// remain.
+func (f *conversationFilter) apply(conv Conversation) (Conversation, bool) {
+	if f.types != nil && !f.types[conv.Type] {
+		return conv, false
+	}
+
+	for _, exclude := range f.excludeLabels {
+		for _, label := range conv.Labels {
+			if strings.EqualFold(label, exclude) {
+				return conv, false
+			}
+		}
+	}
+
+	if f.participant != "" && !f.matchParticipant(conv) {
+		return conv, false
+	}
+
+	if conv.Type == "chat" {
+		if f.since.IsZero() && f.until.IsZero() {
+			return conv, true
+		}
+		var msgs []Message
+		for _, m := range conv.Messages {
+			if f.inRange(m.Timestamp) {
+				msgs = append(msgs, m)
+			}
+		}
+		if len(msgs) == 0 {
+			return conv, false
+		}
+		conv.Messages = msgs
+		conv.Timestamp = msgs[0].Timestamp
+		return conv, true
+	}
+
+	return conv, f.inRange(conv.Timestamp)
+}
+
+func (f *conversationFilter) inRange(t time.Time) bool {
+	if !f.since.IsZero() && t.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !t.Before(f.until) {
+		return false
+	}
+	return true
+}
+
+// matchParticipant does a case insensitive substring match against participant
+// names. If the query contains digits it is also compared against participant
+// numbers ignoring formatting, so "555-0100" matches "+15550100".
+func (f *conversationFilter) matchParticipant(conv Conversation) bool {
+	query := strings.ToLower(f.participant)
+	queryDigits := digitsOnly(f.participant)
+
+	for _, p := range conv.Participants {
+		if strings.Contains(strings.ToLower(p.Name), query) {
+			return true
+		}
+		if queryDigits != "" && strings.Contains(digitsOnly(p.Number), queryDigits) {
+			return true
+		}
+	}
+	return false
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseLabels(t *testing.T) {
+	input, err := os.ReadFile("testdata/voicemail.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conv, err := parseHTML(string(input))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	expected := []string{"Voicemail", "Inbox"}
+	if len(conv.Labels) != len(expected) {
+		t.Fatalf("Expected labels %v, got %v", expected, conv.Labels)
+	}
+	for i, l := range expected {
+		if conv.Labels[i] != l {
+			t.Errorf("Label %d: Expected %s, got %s", i, l, conv.Labels[i])
+		}
+	}
+}
+
+func TestConversationFilter(t *testing.T) {
+	input, err := os.ReadFile("testdata/sms.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sms, err := parseHTML(string(input))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	pacific := time.FixedZone("Pacific Time", -7*60*60)
+
+	sinceTime, err := parseFilterTime("2022-06-30T18:07:00-07:00", pacific, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untilDay, err := parseFilterTime("2022-06-30", pacific, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   conversationFilter
+		keep     bool
+		messages int
+	}{
+		{
+			name:     "no filter",
+			keep:     true,
+			messages: 5,
+		},
+		{
+			name:   "type excluded",
+			filter: conversationFilter{types: map[string]bool{"voicemail": true}},
+			keep:   false,
+		},
+		{
+			name:     "type included",
+			filter:   conversationFilter{types: map[string]bool{"chat": true}},
+			keep:     true,
+			messages: 5,
+		},
+		{
+			name:     "since trims messages",
+			filter:   conversationFilter{since: sinceTime},
+			keep:     true,
+			messages: 3,
+		},
+		{
+			name:     "until is inclusive of the day",
+			filter:   conversationFilter{until: untilDay},
+			keep:     true,
+			messages: 5,
+		},
+		{
+			name:   "until before thread",
+			filter: conversationFilter{until: untilDay.AddDate(0, 0, -1)},
+			keep:   false,
+		},
+		{
+			name:     "participant by name",
+			filter:   conversationFilter{participant: "smehrik"},
+			keep:     true,
+			messages: 5,
+		},
+		{
+			name:     "participant by number",
+			filter:   conversationFilter{participant: "(333)"},
+			keep:     true,
+			messages: 5,
+		},
+		{
+			name:   "participant not present",
+			filter: conversationFilter{participant: "Mike Truk"},
+			keep:   false,
+		},
+		{
+			name:   "excluded label",
+			filter: conversationFilter{excludeLabels: []string{"inbox"}},
+			keep:   false,
+		},
+		{
+			name:     "other label",
+			filter:   conversationFilter{excludeLabels: []string{"Spam"}},
+			keep:     true,
+			messages: 5,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.filter.apply(sms)
+			if ok != tc.keep {
+				t.Fatalf("Expected keep=%t, got %t", tc.keep, ok)
+			}
+			if !ok {
+				return
+			}
+			if len(got.Messages) != tc.messages {
+				t.Errorf("Expected %d messages, got %d", tc.messages, len(got.Messages))
+			}
+			if !got.Timestamp.Equal(got.Messages[0].Timestamp) {
+				t.Errorf("Expected conversation timestamp %v to match first message %v", got.Timestamp, got.Messages[0].Timestamp)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Greeting is a voicemail greeting recorded by the account owner, from the
+// takeout's Greetings folder.
+type Greeting struct {
+	Name       string    `json:"name"`
+	RecordedAt time.Time `json:"recorded_at"`
+	// Path is the greeting's audio file. Writers read the content from it.
+	Path    string `json:"file_name"`
+	Account string `json:"account,omitempty"`
+}
+
+// GreetingWriter is implemented by output writers that can store voicemail
+// greetings.
+type GreetingWriter interface {
+	WriteGreetings([]Greeting) error
+}
+
+var greetingExtensions = map[string]bool{
+	".mp3": true,
+	".wav": true,
+	".ogg": true,
+	".m4a": true,
+}
+
+// greetingTimestampRe matches the UTC timestamp Google embeds in takeout
+// file names, e.g. "Greeting - 2016-02-14T20_31_07Z.mp3".
+var greetingTimestampRe = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}_\d{2}_\d{2}Z`)
+
+// findTakeoutDir is findTakeoutFile for directories.
+func findTakeoutDir(name string) (string, bool) {
+	for _, dir := range []string{".", ".."} {
+		p := filepath.Join(dir, name)
+		if st, err := os.Stat(p); err == nil && st.IsDir() {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// findGreetings lists the audio files in dir. The greeting name and
+// recording time come from the file name, falling back to the file's
+// modification time if the name has no timestamp.
+func findGreetings(dir string) ([]Greeting, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var greetings []Greeting
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || !greetingExtensions[ext] {
+			continue
+		}
+
+		g := Greeting{
+			Path: filepath.Join(dir, e.Name()),
+		}
+
+		stem := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		if loc := greetingTimestampRe.FindStringIndex(stem); loc != nil {
+			ts := strings.ReplaceAll(stem[loc[0]:loc[1]], "_", ":")
+			if t, err := time.Parse(time.RFC3339, ts); err == nil {
+				g.RecordedAt = t
+			}
+			stem = strings.TrimRight(stem[:loc[0]]+stem[loc[1]:], " -")
+		}
+		g.Name = strings.TrimSpace(stem)
+		if g.Name == "" {
+			g.Name = "Greeting"
+		}
+
+		if g.RecordedAt.IsZero() {
+			info, err := e.Info()
+			if err != nil {
+				return nil, fmt.Errorf("stat %s: %w", g.Path, err)
+			}
+			g.RecordedAt = info.ModTime()
+		}
+
+		greetings = append(greetings, g)
+	}
+
+	sort.Slice(greetings, func(i, j int) bool {
+		return greetings[i].RecordedAt.Before(greetings[j].RecordedAt)
+	})
+	return greetings, nil
+}
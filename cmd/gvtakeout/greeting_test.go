@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindGreetings(t *testing.T) {
+	dir := t.TempDir()
+
+	modTime := time.Date(2012, 1, 2, 3, 4, 5, 0, time.UTC)
+	files := map[string]time.Time{
+		"Greeting - 2016-02-14T20_31_07Z.mp3": time.Time{},
+		"Away message.mp3":                    modTime,
+		"notes.txt":                           time.Time{},
+	}
+	for name, mtime := range files {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("ID3"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if !mtime.IsZero() {
+			if err := os.Chtimes(p, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	greetings, err := findGreetings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Greeting{
+		{Name: "Away message", RecordedAt: modTime, Path: filepath.Join(dir, "Away message.mp3")},
+		{Name: "Greeting", RecordedAt: time.Date(2016, 2, 14, 20, 31, 7, 0, time.UTC), Path: filepath.Join(dir, "Greeting - 2016-02-14T20_31_07Z.mp3")},
+	}
+	if len(greetings) != len(expected) {
+		t.Fatalf("Expected %d greetings, got %d: %+v", len(expected), len(greetings), greetings)
+	}
+	for i, g := range expected {
+		got := greetings[i]
+		if got.Name != g.Name || !got.RecordedAt.Equal(g.RecordedAt) || got.Path != g.Path {
+			t.Errorf("Greeting %d: Expected %+v, got %+v", i, g, got)
+		}
+	}
+}
+
+func TestGreetingReimport(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "conversations.db")
+	abs, err := filepath.Abs("testdata/Dwigt Rortugal - Recorded - 2012-03-04T18_11_12Z.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same greeting imported again, read from elsewhere, keeps its row
+	// and ID.
+	var ids []string
+	for _, path := range []string{"testdata/Dwigt Rortugal - Recorded - 2012-03-04T18_11_12Z.mp3", abs} {
+		w := &sqliteWriter{dbName: dbName}
+		if err := w.Begin(); err != nil {
+			t.Fatal(err)
+		}
+		g := Greeting{Name: "Greeting", RecordedAt: time.Date(2016, 2, 14, 20, 31, 7, 0, time.UTC), Path: path, Account: "+2222"}
+		if err := w.WriteGreetings([]Greeting{g}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := queryStrings(w.db, "SELECT id || ' ' || uid FROM greeting")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("Expected one greeting, got %v", got)
+		}
+		ids = append(ids, got[0])
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("Expected the greeting to keep its row and ID, got %v", ids)
+	}
+
+	db, err = openReadOnly(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	displayLoc = time.UTC
+	defer func() {
+		db.Close()
+		db, displayLoc = nil, nil
+	}()
+
+	greetings, err := getGreetings("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(greetings) != 1 {
+		t.Fatalf("Expected one greeting, got %+v", greetings)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /greeting/{id}/audio", greetingAudioHandler)
+	for id, want := range map[string]int{greetings[0].UID: http.StatusOK, "1": http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/greeting/"+id+"/audio", nil))
+		if rec.Code != want {
+			t.Errorf("Greeting %s: Expected %d, got %d", id, want, rec.Code)
+		}
+	}
+}
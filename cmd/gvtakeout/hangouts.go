@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Before Google Voice texts moved to the HTML layout they were Hangouts
+// conversations, and the Hangouts takeout's Hangouts.json holds them along
+// with ordinary Hangouts chats. Texts sent through Voice have the phone
+// numbers of their participants, so they join the Voice threads.
+
+// hangoutsOrigin is the Conversation.Origin of conversations from
+// Hangouts.json.
+const hangoutsOrigin = "hangouts"
+
+// hangoutsTakeout is Hangouts.json. Older takeouts nest each conversation
+// under conversation_state instead.
+type hangoutsTakeout struct {
+	Conversations []hangoutsConversation `json:"conversations"`
+	State         []struct {
+		State struct {
+			Conversation hangoutsConversationInfo `json:"conversation"`
+			Events       []hangoutsEvent          `json:"event"`
+		} `json:"conversation_state"`
+	} `json:"conversation_state"`
+}
+
+type hangoutsConversation struct {
+	Conversation struct {
+		Conversation hangoutsConversationInfo `json:"conversation"`
+	} `json:"conversation"`
+	Events []hangoutsEvent `json:"events"`
+}
+
+type hangoutsConversationInfo struct {
+	SelfState struct {
+		ReadState struct {
+			ParticipantID hangoutsID `json:"participant_id"`
+		} `json:"self_read_state"`
+	} `json:"self_conversation_state"`
+	Participants []struct {
+		ID           hangoutsID `json:"id"`
+		FallbackName string     `json:"fallback_name"`
+		PhoneNumber  struct {
+			E164 string `json:"e164"`
+		} `json:"phone_number"`
+	} `json:"participant_data"`
+}
+
+type hangoutsID struct {
+	GaiaID string `json:"gaia_id"`
+}
+
+type hangoutsEvent struct {
+	SenderID    hangoutsID `json:"sender_id"`
+	Timestamp   string     `json:"timestamp"`
+	ChatMessage *struct {
+		Content struct {
+			Segments []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"segment"`
+			Attachments []struct {
+				EmbedItem struct {
+					PlusPhoto struct {
+						URL string `json:"url"`
+					} `json:"plus_photo"`
+				} `json:"embed_item"`
+			} `json:"attachment"`
+		} `json:"message_content"`
+	} `json:"chat_message"`
+}
+
+func runImportHangouts(args []string) error {
+	return runExternalImport("import-hangouts", "<Hangouts.json>...", args, func(name, account string, known knownContacts, loc *time.Location) ([]Conversation, error) {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return parseHangouts(b, filepath.Base(name), account, known, loc)
+	})
+}
+
+// parseHangouts returns the conversations in Hangouts.json that have
+// messages. Each Hangouts conversation is one chat.
+func parseHangouts(b []byte, sourceFile, account string, known knownContacts, loc *time.Location) ([]Conversation, error) {
+	var takeout hangoutsTakeout
+	if err := json.Unmarshal(b, &takeout); err != nil {
+		return nil, err
+	}
+	for _, s := range takeout.State {
+		var c hangoutsConversation
+		c.Conversation.Conversation = s.State.Conversation
+		c.Events = s.State.Events
+		takeout.Conversations = append(takeout.Conversations, c)
+	}
+
+	var convs []Conversation
+	for _, hc := range takeout.Conversations {
+		info := hc.Conversation.Conversation
+		self := info.SelfState.ReadState.ParticipantID.GaiaID
+
+		conv := Conversation{Type: "chat", SourceFile: sourceFile, Account: account, Origin: hangoutsOrigin}
+		people := make(map[string]Participant)
+		for _, p := range info.Participants {
+			var q Participant
+			if p.ID.GaiaID == self {
+				q = known.me(participantFromGroup)
+			} else if p.PhoneNumber.E164 != "" {
+				q = known.contact(p.PhoneNumber.E164, p.FallbackName, participantFromGroup)
+			} else {
+				q = Participant{Name: p.FallbackName, Source: participantFromGroup}
+				if q.Name == "" {
+					q.Name = "Unknown"
+				}
+			}
+			people[p.ID.GaiaID] = q
+			conv.Participants.add(q)
+		}
+
+		for _, ev := range hc.Events {
+			if ev.ChatMessage == nil {
+				continue
+			}
+			us, err := strconv.ParseInt(ev.Timestamp, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad event timestamp %q: %w", ev.Timestamp, err)
+			}
+			sender, ok := people[ev.SenderID.GaiaID]
+			if !ok {
+				sender = Participant{Name: "Unknown", Source: participantFromMessage}
+				conv.Participants.add(sender)
+			}
+
+			var text strings.Builder
+			for _, seg := range ev.ChatMessage.Content.Segments {
+				if seg.Type == "LINE_BREAK" && seg.Text == "" {
+					text.WriteString("\n")
+					continue
+				}
+				text.WriteString(seg.Text)
+			}
+			m := Message{
+				Timestamp:    time.UnixMicro(us).In(loc),
+				Sender:       sender.Name,
+				SenderNumber: sender.Number,
+				Content:      text.String(),
+			}
+			for _, a := range ev.ChatMessage.Content.Attachments {
+				if url := a.EmbedItem.PlusPhoto.URL; url != "" {
+					m.Images = append(m.Images, url)
+				}
+			}
+			conv.Messages = append(conv.Messages, m)
+		}
+		if len(conv.Messages) == 0 {
+			continue
+		}
+
+		finishChat(&conv)
+		conv.assignIDs()
+		convs = append(convs, conv)
+	}
+	return convs, nil
+}
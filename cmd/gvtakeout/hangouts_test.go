@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseHangouts(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "Hangouts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := knownContacts{
+		self:    Participant{Name: selfName, Number: "+2222", IsSelf: true},
+		numbers: map[string]Participant{"+333": {Name: "Tony Smehrik", Number: "+333"}},
+	}
+	convs, err := parseHangouts(b, "Hangouts.json", "+2222", known, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 2 {
+		t.Fatalf("Expected the two conversations with messages, got %d", len(convs))
+	}
+
+	sms := convs[0]
+	var names []string
+	for _, p := range sms.Participants {
+		names = append(names, p.Name+" "+p.Number)
+	}
+	if !reflect.DeepEqual(names, []string{"Me +2222", "Tony Smehrik +333"}) {
+		t.Errorf("Expected the SMS thread's participants named as the database names them, got %v", names)
+	}
+	last := sms.Messages[len(sms.Messages)-1]
+	if last.Sender != "Tony Smehrik" || last.Content != "see you\nsoon" {
+		t.Errorf("Expected the segments joined across the line break, got %+v", last)
+	}
+	if sms.Origin != hangoutsOrigin || sms.ID == "" || !sms.Timestamp.Equal(sms.Messages[0].Timestamp) {
+		t.Errorf("Expected an identified Hangouts conversation starting at its first message, got %+v", sms)
+	}
+
+	chat := convs[1]
+	if len(chat.Messages) != 1 {
+		t.Fatalf("Expected membership events to be skipped, got %+v", chat.Messages)
+	}
+	m := chat.Messages[0]
+	if m.Sender != "Mike Truk" || m.SenderNumber != "" || !reflect.DeepEqual(m.Images, []string{"https://lh3.googleusercontent.com/-abc/fireworks.jpg"}) {
+		t.Errorf("Expected Mike Truk's photo message, got %+v", m)
+	}
+}
+
+func TestImportHangouts(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "conversations.db")
+	w := &sqliteWriter{dbName: dbName}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	voice := parseTestdata(t, "sms.html")
+	for _, conv := range voice {
+		conv.Account = "+2222"
+		conv.Participants.markSelf(conv.Account)
+		if err := w.Write(conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runImportHangouts([]string{"-db", dbName, filepath.Join("testdata", "Hangouts.json")}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := openReadOnly(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sms := voice[0]
+	sms.Account = "+2222"
+	sms.Participants.markSelf(sms.Account)
+	sms.assignIDs()
+
+	var convs, msgs int
+	err = db.QueryRow(`SELECT COUNT(DISTINCT conversation.id), COUNT(message.id)
+		FROM conversation JOIN message ON message.conversation_id = conversation.id
+		WHERE conversation.thread_uid = ?`, sms.ThreadID).Scan(&convs, &msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if convs != 2 || msgs != len(sms.Messages)+1 {
+		t.Errorf("Expected the Hangouts texts in the Voice thread without repeats, got %d conversations and %d messages", convs, msgs)
+	}
+
+	var dups int
+	if err := db.QueryRow("SELECT COUNT(*) FROM duplicate_message WHERE source_file = 'Hangouts.json'").Scan(&dups); err != nil {
+		t.Fatal(err)
+	}
+	if dups != 2 {
+		t.Errorf("Expected 2 duplicates from Hangouts.json, got %d", dups)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Contacts, threads, conversations, messages, and greetings have stable IDs
+// derived from what they are rather than where they were read from, so the
+// IDs stay the same when the database is rebuilt from the takeout or another
+// takeout is imported. The database's own integer ids are not stable and are never
+// shown.
+
+// stableID returns the first 16 hex digits of the SHA-256 hash of parts.
+func stableID(kind string, parts ...string) string {
+	h := sha256.New()
+	h.Write([]byte(kind))
+	for _, p := range parts {
+		h.Write([]byte{0})
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// contactUID identifies a contact the way the contact table does: by
+// account, name and number.
+func contactUID(account string, p Participant) string {
+	return stableID("contact", account, p.Name, p.Number)
+}
+
+// threadUID identifies the thread of conversations between a set of
+// contacts. The viewer's groups are threads.
+func threadUID(account string, contactUIDs []string) string {
+	uids := append([]string(nil), contactUIDs...)
+	sort.Strings(uids)
+	parts := []string{account}
+	for i, uid := range uids {
+		if i == 0 || uid != uids[i-1] {
+			parts = append(parts, uid)
+		}
+	}
+	return stableID("thread", parts...)
+}
+
+// conversationUID identifies a conversation. Texts are one conversation per
+// thread and origin, whatever messages a takeout holds, so the ID doesn't
+// depend on which takeout was imported first. A call is one conversation
+// per call, identified by when it happened.
+func conversationUID(c Conversation) string {
+	if c.Type == "chat" {
+		return stableID("conversation", c.Account, c.ThreadID, c.Type, c.Origin)
+	}
+	return stableID("conversation", c.Account, c.ThreadID, c.Type, strconv.FormatInt(c.Timestamp.UnixMilli(), 10))
+}
+
+// messageUID identifies a message by its sender number, timestamp and
+// content. Image file names differ between thread files, so only their
+// count is part of the ID.
+func messageUID(account string, m Message) string {
+	sender := digitsOnly(m.SenderNumber)
+	if sender == "" {
+		sender = m.Sender
+	}
+	return stableID("message", account, sender, strconv.FormatInt(m.Timestamp.UnixMilli(), 10), m.Content, strconv.Itoa(len(m.Images)))
+}
+
+// greetingUID identifies a greeting by its account and audio file name, so
+// re-importing a greeting keeps its ID wherever the takeout is read from.
+func greetingUID(g Greeting) string {
+	return stableID("greeting", g.Account, filepath.Base(g.Path))
+}
+
+// assignIDs sets the stable IDs of the conversation, its participants and
+// its messages. It must be called after the account is set.
+func (c *Conversation) assignIDs() {
+	contacts := make([]string, len(c.Participants))
+	for i := range c.Participants {
+		c.Participants[i].ContactID = contactUID(c.Account, c.Participants[i])
+		contacts[i] = c.Participants[i].ContactID
+	}
+	c.ThreadID = threadUID(c.Account, contacts)
+	c.ID = conversationUID(*c)
+	for i := range c.Messages {
+		c.Messages[i].ID = messageUID(c.Account, c.Messages[i])
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStableIDs(t *testing.T) {
+	names := []string{"sms.html", "mms.html", "missedcall.html"}
+	first := importTestdata(t, names...)
+	second := importTestdata(t, "missedcall.html", "mms.html", "sms.html")
+
+	for _, query := range []string{
+		"SELECT uid FROM contact ORDER BY uid",
+		"SELECT uid || ' ' || thread_uid FROM conversation ORDER BY uid",
+		"SELECT uid FROM message ORDER BY uid",
+	} {
+		a, err := queryStrings(first, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := queryStrings(second, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(a, ",") != strings.Join(b, ",") || len(a) == 0 {
+			t.Errorf("%s: expected the same IDs from both imports, got %v and %v", query, a, b)
+		}
+	}
+
+	sms := parseTestdata(t, "sms.html")[0]
+	sms.Account = "+2222"
+	sms.assignIDs()
+	if sms.Participants[0].ContactID == sms.Participants[1].ContactID || sms.Messages[0].ID == sms.Messages[1].ID {
+		t.Errorf("Expected distinct IDs, got %+v", sms)
+	}
+	mms := parseTestdata(t, "mms.html")[0]
+	mms.Account = "+2222"
+	mms.assignIDs()
+	if sms.ThreadID == mms.ThreadID {
+		t.Errorf("Expected threads with different participants to differ")
+	}
+}
+
+func TestGrownThread(t *testing.T) {
+	sms := parseTestdata(t, "sms.html")[0]
+	older := sms
+	older.Messages = sms.Messages[:2]
+	db := importConversations(t, []Conversation{older, sms})
+
+	var convs, msgs int
+	if err := db.QueryRow("SELECT COUNT(*) FROM conversation").Scan(&convs); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM message").Scan(&msgs); err != nil {
+		t.Fatal(err)
+	}
+	if convs != 1 || msgs != len(sms.Messages) {
+		t.Errorf("Expected the later file to add to the stored conversation, got %d conversations and %d messages", convs, msgs)
+	}
+}
+
+func TestImportOrder(t *testing.T) {
+	sms := parseTestdata(t, "sms.html")[0]
+	// An older takeout of the thread, and a newer one that begins later
+	// but holds a message the older one doesn't.
+	older := sms
+	older.SourceFile = "older.html"
+	older.Messages = sms.Messages[:2]
+	newer := sms
+	newer.SourceFile = "newer.html"
+	newer.Messages = sms.Messages[1:]
+	newer.Timestamp = newer.Messages[0].Timestamp
+
+	query := "SELECT uid || ' ' || timestamp_ms || ' ' || (SELECT COUNT(*) FROM message WHERE conversation_id = conversation.id) FROM conversation"
+	a, err := queryStrings(importConversations(t, []Conversation{older, newer}), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := queryStrings(importConversations(t, []Conversation{newer, older}), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 1 || strings.Join(a, ",") != strings.Join(b, ",") {
+		t.Errorf("Expected one conversation with the same ID, start and messages in either order, got %v and %v", a, b)
+	}
+	if !strings.HasSuffix(a[0], fmt.Sprintf(" %d %d", sms.Timestamp.UnixMilli(), len(sms.Messages))) {
+		t.Errorf("Expected the conversation to start with the older takeout and hold every message, got %v", a)
+	}
+}
+
+func TestThreadGrownBackwards(t *testing.T) {
+	sms := parseTestdata(t, "sms.html")[0]
+	first := sms.Messages[0]
+	earlier := first
+	earlier.Timestamp = first.Timestamp.Add(-time.Hour)
+	earlier.Content = "an hour before"
+
+	// The same conversation with a message older than any stored, which
+	// gets a higher id than the messages after it.
+	grown := sms
+	grown.Messages = append([]Message{earlier}, sms.Messages...)
+	db := importConversations(t, []Conversation{sms, grown})
+
+	var ms int64
+	if err := db.QueryRow("SELECT timestamp_ms FROM conversation").Scan(&ms); err != nil {
+		t.Fatal(err)
+	}
+	if ms != earlier.Timestamp.UnixMilli() {
+		t.Errorf("Expected the conversation to start with the added message at %d, got %d", earlier.Timestamp.UnixMilli(), ms)
+	}
+
+	results, err := searchMessages(db, first.Content, "", 0, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %+v", results)
+	}
+	r := results[0]
+	if err := r.loadContext(db, 1, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Before) != 1 || r.Before[0].Content != earlier.Content {
+		t.Errorf("Expected the added message before the match, got %+v", r.Before)
+	}
+	if len(r.After) != 1 || r.After[0].Content != sms.Messages[1].Content {
+		t.Errorf("Expected the second message after the match, got %+v", r.After)
+	}
+}
+
+func TestGroupRoute(t *testing.T) {
+	var err error
+	templates, _, err = loadAssets("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = importTestdata(t, "sms.html", "reactions.html", "mms.html")
+	displayLoc = time.UTC
+	defer func() { db, displayLoc, templates = nil, nil, nil }()
+
+	sms := parseTestdata(t, "sms.html")[0]
+	sms.Account = "+2222"
+	sms.assignIDs()
+
+	req := httptest.NewRequest("GET", "/group/"+sms.ThreadID, nil)
+	req.SetPathValue("thread", sms.ThreadID)
+	rec := httptest.NewRecorder()
+	groupHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "all that space") || !strings.Contains(body, "See you then") || strings.Contains(body, "hornet-skyscraper") {
+		t.Errorf("Expected the thread's two conversations and nothing else, got:\n%s", body)
+	}
+	if !strings.Contains(body, `id="`+sms.Messages[0].ID+`"`) {
+		t.Errorf("Expected an anchor for message %s", sms.Messages[0].ID)
+	}
+}
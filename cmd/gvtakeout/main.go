@@ -0,0 +1,197 @@
+// Command gvtakeout parses Google Voice takeouts into JSON or SQLite and
+// browses, searches, and checks the resulting database.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// command is a gvtakeout subcommand. run receives the arguments after the
+// command name.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"parse", "Parse the takeout in the current directory to newline delimited JSON", runParse},
+		{"import", "Import the takeout in the current directory into the SQLite database", runImport},
+		{"import-android", "Import an Android SMS Backup & Restore file into the SQLite database", runImportAndroid},
+		{"import-hangouts", "Import a Hangouts takeout's Hangouts.json into the SQLite database", runImportHangouts},
+		{"import-chat", "Import a Google Chat export into the SQLite database", runImportChat},
+		{"import-contacts", "Add names from a Google Contacts export to the SQLite database", runImportContacts},
+		{"serve", "Browse the database in a web browser", runServe},
+		{"browse", "Browse the database in the terminal", runBrowse},
+		{"export", "Write the database back out as newline delimited JSON", runExport},
+		{"stats", "Summarize what the database contains", runStats},
+		{"search", "Search message text and transcripts", runSearch},
+		{"show", "Print a conversation as a transcript", runShow},
+		{"verify", "Check the database for corruption and missing data", runVerify},
+		{"redact", "Write an anonymized copy of a takeout or of parsed JSON", runRedact},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	}
+
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "gvtakeout: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gvtakeout <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'gvtakeout <command> -h' for the flags of a command.\n")
+}
+
+// dbEnv, if set, is the default for -db.
+const dbEnv = "GVTAKEOUT_DB"
+
+// commonFlags are accepted by every command so the database, time zone, and
+// logging are configured the same way everywhere.
+type commonFlags struct {
+	db       string
+	tz       string
+	logLevel string
+	identity string
+}
+
+// newFlagSet returns a flag set for the named command with the common flags
+// registered. args describes the positional arguments for the usage line.
+func newFlagSet(name, args string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gvtakeout %s [flags] %s\n\n", name, args)
+		fs.PrintDefaults()
+	}
+
+	defaultDB := os.Getenv(dbEnv)
+	if defaultDB == "" {
+		defaultDB = "conversations.db"
+	}
+
+	c := &commonFlags{}
+	fs.StringVar(&c.db, "db", defaultDB, "SQLite database, age encrypted if it ends in .age (default from $"+dbEnv+")")
+	fs.StringVar(&c.tz, "tz", "", "IANA time zone for timestamps (default: keep the offset from the takeout)")
+	fs.StringVar(&c.logLevel, "log-level", "info", "Log level: debug, info, warn, or error")
+	fs.StringVar(&c.identity, "identity", "", "age identity file for an encrypted database (default: prompt for a passphrase)")
+	return fs, c
+}
+
+// setup applies -log-level and returns the -tz location, or nil if -tz is
+// unset.
+func (c *commonFlags) setup() (*time.Location, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.logLevel)); err != nil {
+		return nil, fmt.Errorf("invalid -log-level %q", c.logLevel)
+	}
+	slog.SetLogLoggerLevel(level)
+
+	if c.tz == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(c.tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", c.tz, err)
+	}
+	return loc, nil
+}
+
+// openDB opens -db read-only, decrypting it to a private temporary file
+// first if it is age encrypted. The returned function closes the database
+// and removes any decrypted copy.
+func (c *commonFlags) openDB() (*sql.DB, func(), error) {
+	path := c.db
+	cleanup := func() {}
+	if strings.HasSuffix(path, ".age") {
+		var err error
+		path, cleanup, err = decryptDB(path, c.identity)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decrypt %s: %w", c.db, err)
+		}
+	} else if _, err := os.Stat(path); err != nil {
+		return nil, nil, err
+	}
+
+	d, err := openReadOnly(path)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := checkSchema(d); err != nil {
+		d.Close()
+		cleanup()
+		return nil, nil, fmt.Errorf("%s: %w", c.db, err)
+	}
+	return d, func() {
+		d.Close()
+		cleanup()
+	}, nil
+}
+
+// openReadOnly opens the database read-only; query_only also rejects writes
+// on the connection itself. The parser leaves databases in WAL mode, which
+// SQLite can't read without creating a -shm file, so if the directory isn't
+// writable fall back to opening the file as immutable.
+func openReadOnly(path string) (*sql.DB, error) {
+	uri := "file:" + (&url.URL{Path: path}).EscapedPath()
+	for _, params := range []string{"?mode=ro&_pragma=query_only(1)", "?mode=ro&immutable=1&_pragma=query_only(1)"} {
+		d, err := sql.Open("sqlite", uri+params)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = d.Exec("SELECT COUNT(*) FROM sqlite_master"); err == nil {
+			return d, nil
+		}
+		d.Close()
+		if !strings.Contains(err.Error(), "readonly") {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		slog.Info("database is in a read-only directory, opening it as immutable", "file", path)
+	}
+	return nil, fmt.Errorf("%s: cannot open read-only", path)
+}
+
+// localTime converts a stored UTC epoch millisecond timestamp into loc, or
+// into the offset recorded in the takeout if loc is nil.
+func localTime(ms int64, offset int, loc *time.Location) time.Time {
+	t := time.UnixMilli(ms)
+	if loc != nil {
+		return t.In(loc)
+	}
+	return t.In(time.FixedZone("", offset))
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"log/slog"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -28,8 +29,8 @@ func TestParseVoicemail(t *testing.T) {
 
 	expected := Conversation{
 		Type: "voicemail",
-		Participants: map[string]string{
-			"Sleve Mcdichael": "+11111111111",
+		Participants: participantList{
+			{Name: "Sleve Mcdichael", Number: "+11111111111", Source: participantFromCall},
 		},
 		Timestamp:  time.Date(2018, 7, 23, 9, 23, 31, 0, time.FixedZone("Pacific Time", -7*60*60)),
 		Duration:   "00:00:18",
@@ -48,14 +49,8 @@ func TestParseVoicemail(t *testing.T) {
 	if conv.Transcript != expected.Transcript {
 		t.Errorf("Expected transcript %s, got %s", expected.Transcript, conv.Transcript)
 	}
-	if len(conv.Participants) != len(expected.Participants) {
-		t.Errorf("Expected %d participants, got %d", len(expected.Participants), len(conv.Participants))
-	} else {
-		for name, number := range expected.Participants {
-			if conv.Participants[name] != number {
-				t.Errorf("Expected participant %s with number %s, got %s", name, number, conv.Participants[name])
-			}
-		}
+	if !reflect.DeepEqual(conv.Participants, expected.Participants) {
+		t.Errorf("Expected participants %+v, got %+v", expected.Participants, conv.Participants)
 	}
 }
 
@@ -72,9 +67,9 @@ func TestParseSMS(t *testing.T) {
 
 	expected := Conversation{
 		Type: "chat",
-		Participants: map[string]string{
-			"Me":           "+2222",
-			"Tony Smehrik": "+333",
+		Participants: participantList{
+			{Name: "Me", Number: "+2222", IsSelf: true, Source: participantFromMessage},
+			{Name: "Tony Smehrik", Number: "+333", Source: participantFromMessage},
 		},
 		Messages: []Message{
 			{
@@ -114,15 +109,8 @@ func TestParseSMS(t *testing.T) {
 	if conv.Type != expected.Type {
 		t.Errorf("Expected type %s, got %s", expected.Type, conv.Type)
 	}
-	if len(conv.Participants) != len(expected.Participants) {
-		t.Errorf("Expected %d participants, got %d", len(expected.Participants), len(conv.Participants))
-		log.Printf("expected=%+v got=%+v", expected.Participants, conv.Participants)
-	} else {
-		for name, number := range expected.Participants {
-			if conv.Participants[name] != number {
-				t.Errorf("Expected participant %s with number %s, got %s", name, number, conv.Participants[name])
-			}
-		}
+	if !reflect.DeepEqual(conv.Participants, expected.Participants) {
+		t.Errorf("Expected participants %+v, got %+v", expected.Participants, conv.Participants)
 	}
 	if len(conv.Messages) != len(expected.Messages) {
 		t.Errorf("Expected %d messages, got %d", len(expected.Messages), len(conv.Messages))
@@ -164,10 +152,10 @@ func TestParseGroupMMS(t *testing.T) {
 
 	expected := Conversation{
 		Type: "chat",
-		Participants: map[string]string{
-			"Me":           "+2222",
-			"Mike Truk":    "+8888",
-			"Tony Smehrik": "+333",
+		Participants: participantList{
+			{Name: "Mike Truk", Number: "+8888", Source: participantFromGroup},
+			{Name: "Tony Smehrik", Number: "+333", Source: participantFromGroup},
+			{Name: "Me", Number: "+2222", IsSelf: true, Source: participantFromMessage},
 		},
 		Messages: []Message{
 			{
@@ -212,15 +200,8 @@ func TestParseGroupMMS(t *testing.T) {
 	if conv.Type != expected.Type {
 		t.Errorf("Expected type %s, got %s", expected.Type, conv.Type)
 	}
-	if len(conv.Participants) != len(expected.Participants) {
-		t.Errorf("Expected %d participants, got %d", len(expected.Participants), len(conv.Participants))
-		log.Printf("expected=%+v got=%+v", expected.Participants, conv.Participants)
-	} else {
-		for name, number := range expected.Participants {
-			if conv.Participants[name] != number {
-				t.Errorf("Expected participant %s with number %s, got %s", name, number, conv.Participants[name])
-			}
-		}
+	if !reflect.DeepEqual(conv.Participants, expected.Participants) {
+		t.Errorf("Expected participants %+v, got %+v", expected.Participants, conv.Participants)
 	}
 	if len(conv.Messages) != len(expected.Messages) {
 		t.Errorf("Expected %d messages, got %d", len(expected.Messages), len(conv.Messages))
@@ -266,8 +247,8 @@ func TestParseMissedCall(t *testing.T) {
 
 	expected := Conversation{
 		Type: "missed_call",
-		Participants: map[string]string{
-			"Dwigt Rortugal": "+66666",
+		Participants: participantList{
+			{Name: "Dwigt Rortugal", Number: "+66666", Source: participantFromCall},
 		},
 		Timestamp: time.Date(2009, 9, 17, 17, 26, 41, 0, time.FixedZone("Pacific Time", -7*60*60)),
 	}
@@ -278,13 +259,40 @@ func TestParseMissedCall(t *testing.T) {
 	if !conv.Timestamp.Equal(expected.Timestamp) {
 		t.Errorf("Expected timestamp %v, got %v", expected.Timestamp, conv.Timestamp)
 	}
-	if len(conv.Participants) != len(expected.Participants) {
-		t.Errorf("Expected %d participants, got %d", len(expected.Participants), len(conv.Participants))
-	} else {
-		for name, number := range expected.Participants {
-			if conv.Participants[name] != number {
-				t.Errorf("Expected participant %s with number %s, got %s", name, number, conv.Participants[name])
-			}
+	if !reflect.DeepEqual(conv.Participants, expected.Participants) {
+		t.Errorf("Expected participants %+v, got %+v", expected.Participants, conv.Participants)
+	}
+}
+
+func TestParseCallVariants(t *testing.T) {
+	tests := []struct {
+		file       string
+		typ        string
+		duration   string
+		transcript string
+		audio      string
+	}{
+		{"recordedcall.html", "recorded_call", "00:02:03", "", "Dwigt Rortugal - Recorded - 2012-03-04T18_11_12Z.mp3"},
+		{"voicemail-notranscript.html", "voicemail", "00:00:03", "", "Sleve Mcdichael - Voicemail - 2018-08-01T19_00_00Z.mp3"},
+		{"voicemail.html", "voicemail", "00:00:18", "Hi Peter, this is Sleve Mcdichael. I'm the manager. I believe you have internet. I just have some quick questions for you. Thank you.", "Sleve Mcdichael - Voicemail - 2018-07-23T16_23_31Z.mp3"},
+		{"unknowncall.html", "unknown_call", "00:00:10", "", ""},
+		{"missedcall.html", "missed_call", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		input, err := os.ReadFile("testdata/" + tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conv, err := parseHTML(string(input))
+		if err != nil {
+			t.Fatalf("%s: Failed to parse HTML: %v", tt.file, err)
+		}
+		if conv.Type != tt.typ || conv.Duration != tt.duration || conv.Transcript != tt.transcript || conv.Audio != tt.audio {
+			t.Errorf("%s: Expected %s %q %q %q, got %s %q %q %q", tt.file, tt.typ, tt.duration, tt.transcript, tt.audio, conv.Type, conv.Duration, conv.Transcript, conv.Audio)
+		}
+		if len(conv.Participants) != 1 {
+			t.Errorf("%s: Expected 1 participant, got %v", tt.file, conv.Participants)
 		}
 	}
 }
@@ -303,9 +311,9 @@ func TestParseSMS2(t *testing.T) {
 	expected := Conversation{
 		Type:      "chat",
 		Timestamp: time.Date(2023, 8, 21, 17, 52, 44, 104000000, time.FixedZone("PDT", -7*60*60)),
-		Participants: map[string]string{
-			"Me":             "+2222",
-			"Sillio Sanford": "",
+		Participants: participantList{
+			{Name: "Me", Number: "+2222", IsSelf: true, Source: participantFromMessage},
+			{Name: "Sillio Sanford", Source: participantFromTitle},
 		},
 		Messages: []Message{
 			{
@@ -356,14 +364,8 @@ func TestParseSMS2(t *testing.T) {
 		t.Errorf("Expected type %s, got %s", expected.Timestamp, conv.Timestamp)
 	}
 
-	if len(conv.Participants) != len(expected.Participants) {
-		t.Errorf("Expected %d participants, got %d", len(expected.Participants), len(conv.Participants))
-	} else {
-		for name, number := range expected.Participants {
-			if conv.Participants[name] != number {
-				t.Errorf("Expected participant %s with number %s, got %s", name, number, conv.Participants[name])
-			}
-		}
+	if !reflect.DeepEqual(conv.Participants, expected.Participants) {
+		t.Errorf("Expected participants %+v, got %+v", expected.Participants, conv.Participants)
 	}
 	if len(conv.Messages) != len(expected.Messages) {
 		t.Errorf("Expected %d messages, got %d", len(expected.Messages), len(conv.Messages))
@@ -393,3 +395,34 @@ func TestParseSMS2(t *testing.T) {
 		}
 	}
 }
+
+func TestConversationIn(t *testing.T) {
+	input, err := os.ReadFile("testdata/sms.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conv, err := parseHTML(string(input))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	converted := conv.In(loc)
+	if converted.Timestamp.Location() != loc {
+		t.Errorf("Expected conversation timestamp in %s, got %s", loc, converted.Timestamp.Location())
+	}
+	if !converted.Timestamp.Equal(conv.Timestamp) {
+		t.Errorf("Expected instant %v, got %v", conv.Timestamp, converted.Timestamp)
+	}
+	if got := converted.Messages[0].Timestamp.Format("15:04"); got != "21:06" {
+		t.Errorf("Expected first message at 21:06 New York time, got %s", got)
+	}
+	if conv.Messages[0].Timestamp.Location() == loc {
+		t.Errorf("In modified the original conversation's messages")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"filippo.io/age"
+)
+
+// OutputWriter receives parsed conversations. Begin is called once before
+// the first Write and Close once after the last. When the run fails Close is
+// still called, unless the writer is an Aborter. Any error aborts the run.
+type OutputWriter interface {
+	Begin() error
+	Write(Conversation) error
+	Close() error
+}
+
+// Aborter is implemented by output writers that must not finish their output
+// when a run fails, such as one that would replace an encrypted database
+// with a partial copy. Abort is called instead of Close.
+type Aborter interface {
+	Abort()
+}
+
+// abortOutput ends w after a failed run.
+func abortOutput(w OutputWriter) {
+	if a, ok := w.(Aborter); ok {
+		a.Abort()
+		return
+	}
+	w.Close()
+}
+
+// OutputOptions carries the settings shared by all output formats.
+type OutputOptions struct {
+	// Stdout is where stream oriented formats write their output.
+	Stdout io.Writer
+	// Location, if set, is the zone timestamps should be rendered in.
+	Location *time.Location
+	// Encryption, if set, asks the writer to encrypt everything it writes.
+	// Writers that can't must return an error from their factory.
+	Encryption *Encryption
+	// DBPath is the database file for database formats.
+	DBPath string
+	// LegacyParticipants asks the json format for participants in the old
+	// {"name": "number"} form.
+	LegacyParticipants bool
+}
+
+// OutputWriterFactory creates a writer for one run of the parser.
+type OutputWriterFactory func(opts OutputOptions) (OutputWriter, error)
+
+var outputWriters = make(map[string]OutputWriterFactory)
+
+// RegisterOutputWriter makes an output format available to the -format
+// flag. It is intended to be called from init functions and panics if name
+// is registered twice.
+func RegisterOutputWriter(name string, factory OutputWriterFactory) {
+	if _, dup := outputWriters[name]; dup {
+		panic(fmt.Sprintf("output writer %q registered twice", name))
+	}
+	outputWriters[name] = factory
+}
+
+// outputFormats returns the registered format names in sorted order.
+func outputFormats() []string {
+	names := make([]string, 0, len(outputWriters))
+	for name := range outputWriters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterOutputWriter("json", func(opts OutputOptions) (OutputWriter, error) {
+		out := opts.Stdout
+		var closer io.Closer
+		if opts.Encryption != nil {
+			ew, err := age.Encrypt(opts.Stdout, opts.Encryption.Recipients...)
+			if err != nil {
+				return nil, err
+			}
+			out, closer = ew, ew
+		}
+		return &jsonWriter{enc: json.NewEncoder(out), loc: opts.Location, legacy: opts.LegacyParticipants, closer: closer}, nil
+	})
+}
+
+// jsonWriter writes one JSON object per conversation, newline delimited.
+type jsonWriter struct {
+	enc *json.Encoder
+	loc *time.Location
+	// legacy writes participants as a {"name": "number"} object.
+	legacy bool
+	// closer finishes the age stream when encrypting.
+	closer io.Closer
+}
+
+func (w *jsonWriter) Begin() error {
+	return nil
+}
+
+func (w *jsonWriter) Write(conv Conversation) error {
+	if w.loc != nil {
+		conv = conv.In(w.loc)
+	}
+	var v any = conv
+	if w.legacy {
+		v = newLegacyConversation(conv)
+	}
+	if err := w.enc.Encode(v); err != nil {
+		return fmt.Errorf("marshal JSON for file %s: %w", conv.SourceFile, err)
+	}
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	if w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseTestdata(t *testing.T, names ...string) []Conversation {
+	t.Helper()
+
+	var convs []Conversation
+	for _, name := range names {
+		input, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conv, err := parseHTML(string(input))
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", name, err)
+		}
+		conv.SourceFile = name
+		convs = append(convs, conv)
+	}
+	return convs
+}
+
+func TestJSONWriter(t *testing.T) {
+	convs := parseTestdata(t, "voicemail.html", "sms.html")
+
+	var buf bytes.Buffer
+	w, err := outputWriters["json"](OutputOptions{Stdout: &buf, Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, conv := range convs {
+		if err := w.Write(conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(convs) {
+		t.Fatalf("Expected %d lines, got %d: %s", len(convs), len(lines), buf.String())
+	}
+
+	var got struct {
+		Type      string `json:"type"`
+		Timestamp string `json:"timestamp"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "voicemail" {
+		t.Errorf("Expected voicemail, got %s", got.Type)
+	}
+	if got.Timestamp != "2018-07-23T16:23:31Z" {
+		t.Errorf("Expected UTC timestamp, got %s", got.Timestamp)
+	}
+}
+
+func TestSQLiteWriter(t *testing.T) {
+	convs := parseTestdata(t, "voicemail.html", "sms.html", "mms.html")
+
+	w := &sqliteWriter{dbName: filepath.Join(t.TempDir(), "conversations.db")}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// None of the referenced media files exist, which should not prevent
+	// the conversations from being written.
+	for _, conv := range convs {
+		if err := w.Write(conv); err != nil {
+			t.Fatalf("Write %s: %v", conv.SourceFile, err)
+		}
+	}
+
+	counts := map[string]int{
+		"conversation": 3,
+		"message":      11,
+		"image":        5,
+		"media_file":   0,
+		"call_audio":   1,
+	}
+	for table, want := range counts {
+		var got int
+		if err := w.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Expected %d rows in %s, got %d", want, table, got)
+		}
+	}
+
+	var ms, offset int64
+	err := w.db.QueryRow("SELECT timestamp_ms, utc_offset FROM conversation WHERE type = 'voicemail'").Scan(&ms, &offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms != 1532363011000 || offset != -7*60*60 {
+		t.Errorf("Expected 1532363011000/-25200, got %d/%d", ms, offset)
+	}
+}
+
+func TestSQLiteSchemaVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	// A database from before account, uid and timestamp_ms were added.
+	old := filepath.Join(dir, "old.db")
+	db, err := sql.Open("sqlite", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE conversation (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT, timestamp DATETIME,
+		duration TEXT, transcript TEXT, source_file TEXT)`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &sqliteWriter{dbName: old}
+	err = w.Begin()
+	if err == nil {
+		w.Close()
+		t.Fatal("Expected an old database to be refused")
+	}
+	if !strings.Contains(err.Error(), "conversation.thread_uid") || !strings.Contains(err.Error(), "conversation.timestamp_ms") {
+		t.Errorf("Expected the missing columns in the error, got %v", err)
+	}
+
+	// A current database without a recorded version is accepted and
+	// stamped.
+	current := filepath.Join(dir, "current.db")
+	w = &sqliteWriter{dbName: current}
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.db.Exec("PRAGMA user_version = 0"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	w = &sqliteWriter{dbName: current}
+	if err := w.Begin(); err != nil {
+		t.Fatalf("Expected a complete unversioned database to open, got %v", err)
+	}
+	defer w.Close()
+	var version int
+	if err := w.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != schemaVersion {
+		t.Errorf("Expected schema version %d, got %d", schemaVersion, version)
+	}
+}
+
+func TestSQLiteWriterCallAudio(t *testing.T) {
+	convs := parseTestdata(t, "recordedcall.html", "voicemail-notranscript.html")
+
+	// Media files are found relative to the takeout directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	db := importConversations(t, convs)
+
+	rows, err := db.Query("SELECT conversation.type, call_audio.audio_url, call_audio.content FROM call_audio JOIN conversation ON conversation.id = call_audio.conversation_id ORDER BY conversation.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var typ, url string
+		var content []byte
+		if err := rows.Scan(&typ, &url, &content); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s %s %q", typ, url, content))
+	}
+	want := []string{
+		`recorded_call Dwigt Rortugal - Recorded - 2012-03-04T18_11_12Z.mp3 "ID3\x03\x00\x00\x00\x00\x00\x00recorded call audio"`,
+		`voicemail Sleve Mcdichael - Voicemail - 2018-08-01T19_00_00Z.mp3 ""`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected call audio\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	conv, err := loadConversation(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.Audio != convs[0].Audio {
+		t.Errorf("Expected the audio reference to load back, got %q", conv.Audio)
+	}
+}
@@ -0,0 +1,623 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type Conversation struct {
+	ID           string          `json:"id"`
+	ThreadID     string          `json:"thread_id"`
+	Type         string          `json:"type"`
+	Participants participantList `json:"participants"`
+	Timestamp    time.Time       `json:"timestamp"`
+	Duration     string          `json:"duration,omitempty"`
+	Messages     []Message       `json:"messages,omitempty"`
+	Transcript   string          `json:"transcript,omitempty"`
+	Audio        string          `json:"audio,omitempty"`
+	CallEvent    *CallEvent      `json:"call_event,omitempty"`
+	Labels       []string        `json:"labels,omitempty"`
+	SourceFile   string          `json:"source_file"`
+	Account      string          `json:"account,omitempty"`
+	// Origin is where a conversation imported from outside the Voice
+	// takeout came from, e.g. "android". It is empty for Voice.
+	Origin string `json:"origin,omitempty"`
+}
+
+type Message struct {
+	ID           string     `json:"id"`
+	Timestamp    time.Time  `json:"timestamp"`
+	Sender       string     `json:"sender"`
+	SenderNumber string     `json:"sender_number"`
+	Content      string     `json:"content"`
+	Images       []string   `json:"images,omitempty"`
+	Reactions    []Reaction `json:"reactions,omitempty"`
+}
+
+// In returns a copy of the conversation with all timestamps converted to loc.
+func (c Conversation) In(loc *time.Location) Conversation {
+	c.Timestamp = c.Timestamp.In(loc)
+	if c.Messages != nil {
+		msgs := make([]Message, len(c.Messages))
+		for i, m := range c.Messages {
+			m.Timestamp = m.Timestamp.In(loc)
+			if m.Reactions != nil {
+				reactions := make([]Reaction, len(m.Reactions))
+				for j, r := range m.Reactions {
+					r.Timestamp = r.Timestamp.In(loc)
+					reactions[j] = r
+				}
+				m.Reactions = reactions
+			}
+			msgs[i] = m
+		}
+		c.Messages = msgs
+	}
+	if c.CallEvent != nil {
+		ev := *c.CallEvent
+		ev.MissedCall.Timestamp = ev.MissedCall.Timestamp.In(loc)
+		ev.Voicemail.Timestamp = ev.Voicemail.Timestamp.In(loc)
+		c.CallEvent = &ev
+	}
+	return c
+}
+
+func runParse(args []string) error {
+	return parseTakeout("parse", "json", args)
+}
+
+func runImport(args []string) error {
+	return parseTakeout("import", "sqlite", args)
+}
+
+// parseTakeout parses every HTML file in the current directory and writes
+// the conversations with the output format. parse and import differ only in
+// their default format.
+func parseTakeout(name, defaultFormat string, args []string) error {
+	fs, common := newFlagSet(name, "")
+	format := fs.String("format", defaultFormat, "Output format: "+strings.Join(outputFormats(), ", ")+
+		". Missed calls and voicemails are written after every other conversation so they can be linked")
+	account := fs.String("account", "", "Account this takeout belongs to (default: the Voice number from Phones.vcf)")
+	legacyParticipants := fs.Bool("legacy-participants", false, `Write json participants in the old {"name": "number"} form`)
+	var filters filterFlags
+	filters.register(fs)
+	var encFlags encryptFlags
+	encFlags.register(fs)
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q; run %s from the takeout's Voice/Calls directory", fs.Args(), name)
+	}
+
+	factory, ok := outputWriters[*format]
+	if !ok {
+		return fmt.Errorf("invalid format %q. Use one of: %s", *format, strings.Join(outputFormats(), ", "))
+	}
+
+	loc, err := common.setup()
+	if err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob("*.html")
+	if err != nil {
+		return err
+	}
+
+	filter, err := newConversationFilter(filters, loc)
+	if err != nil {
+		return err
+	}
+
+	parentLgr := slog.Default()
+
+	acct := *account
+	if acct == "" {
+		acct, err = detectAccount()
+		if err != nil {
+			return fmt.Errorf("failed to detect account: %w", err)
+		}
+		if acct != "" {
+			parentLgr.Info("detected account from Phones.vcf", "account", acct)
+		}
+	}
+
+	// An encrypted -db implies -encrypt.
+	if *format == "sqlite" && strings.HasSuffix(common.db, ".age") {
+		encFlags.encrypt = true
+	}
+	enc, err := encFlags.load(common.identity)
+	if err != nil {
+		return fmt.Errorf("encryption setup failed: %w", err)
+	}
+
+	w, err := factory(OutputOptions{Stdout: os.Stdout, Location: loc, Encryption: enc, DBPath: common.db, LegacyParticipants: *legacyParticipants})
+	if err != nil {
+		return fmt.Errorf("failed to create %s writer: %w", *format, err)
+	}
+	if err := w.Begin(); err != nil {
+		return fmt.Errorf("failed to start %s output: %w", *format, err)
+	}
+
+	// Missed calls and voicemails are held back until every file is parsed
+	// so they can be linked into call events. Files aren't read in time
+	// order, so a voicemail's missed call can come from any later file and
+	// nothing can be written before the end.
+	var calls []Conversation
+
+	for _, file := range files {
+		if file == "Bills.html" {
+			// Handled by importAccountData
+			continue
+		}
+
+		lgr := parentLgr.With("file", file)
+		f, err := os.Open(file)
+		if err != nil {
+			lgr.Error("error opening file", "err", err)
+			continue
+		}
+
+		conversation, err := parseFile(lgr, f, file)
+		if err != nil {
+			lgr.Error("error parsing file", "err", err)
+			f.Close()
+			continue
+		}
+
+		f.Close()
+
+		if conversation.Type == "" {
+			lgr.Error("failed to parse file correctly")
+			continue
+		}
+
+		conversation.SourceFile = file
+		conversation.Account = acct
+		conversation.Participants.markSelf(acct)
+
+		conversation, ok := filter.apply(conversation)
+		if !ok {
+			continue
+		}
+		// IDs are assigned once, after filtering may have dropped messages
+		// and moved a chat's start.
+		conversation.assignIDs()
+
+		if isCorrelatedCall(conversation.Type) {
+			calls = append(calls, conversation)
+			continue
+		}
+		if err := w.Write(conversation); err != nil {
+			abortOutput(w)
+			return fmt.Errorf("error writing conversation from %s: %w", file, err)
+		}
+	}
+
+	linkCalls(calls)
+	for _, conversation := range calls {
+		if err := w.Write(conversation); err != nil {
+			abortOutput(w)
+			return fmt.Errorf("error writing conversation from %s: %w", conversation.SourceFile, err)
+		}
+	}
+
+	if err := importAccountData(parentLgr, w, acct, loc); err != nil {
+		abortOutput(w)
+		return fmt.Errorf("failed to import account data: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish %s output: %w", *format, err)
+	}
+	return nil
+}
+
+// parseFile parses a single takeout HTML file. It makes one pass over the
+// token stream, keeping only the stack of open elements, rather than building
+// a DOM and walking it repeatedly.
+func parseFile(lgr *slog.Logger, r io.Reader, filename string) (Conversation, error) {
+	p := fileParser{
+		lgr:      lgr,
+		z:        html.NewTokenizer(r),
+		filename: filename,
+	}
+	return p.parse()
+}
+
+// element is an open tag on the parser's stack. Only the class names and
+// attributes the parser cares about are retained.
+type element struct {
+	tag   atom.Atom
+	class string
+	tel   string
+}
+
+// capture identifies the element whose text is currently being collected.
+type capture int
+
+const (
+	captureNone capture = iota
+	captureTitle
+	captureName
+	captureContent
+	captureTranscript
+	captureDuration
+	captureLabel
+)
+
+var voidElements = map[atom.Atom]bool{
+	atom.Area: true, atom.Base: true, atom.Br: true, atom.Col: true,
+	atom.Embed: true, atom.Hr: true, atom.Img: true, atom.Input: true,
+	atom.Link: true, atom.Meta: true, atom.Param: true, atom.Source: true,
+	atom.Track: true, atom.Wbr: true,
+}
+
+type fileParser struct {
+	lgr      *slog.Logger
+	z        *html.Tokenizer
+	filename string
+	conv     Conversation
+
+	stack []element
+
+	// Depth in stack of the enclosing element of each kind, or 0 if we are
+	// not inside one.
+	chatDepth    int
+	callDepth    int
+	vcardDepth   int
+	messageDepth int
+	citeDepth    int
+	tagsDepth    int
+
+	msg Message
+
+	// call and callTags collect the signals for the type of a call, which
+	// is resolved when its haudio div ends.
+	call     callTypeSignals
+	callTags []string
+
+	capture      capture
+	captureDepth int
+	captureTag   atom.Atom
+	captureTel   string
+	text         strings.Builder
+}
+
+func (p *fileParser) parse() (Conversation, error) {
+	for {
+		switch p.z.Next() {
+		case html.ErrorToken:
+			if err := p.z.Err(); err != io.EOF {
+				return Conversation{}, err
+			}
+			for len(p.stack) > 0 {
+				p.pop()
+			}
+			p.conv.linkReactions()
+			if p.conv.Type == "chat" && len(p.conv.Messages) > 0 {
+				p.conv.Timestamp = p.conv.Messages[0].Timestamp
+			}
+			return p.conv, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			p.startTag()
+		case html.EndTagToken:
+			p.endTag()
+		case html.TextToken:
+			p.handleText(p.z.Text())
+		}
+	}
+}
+
+func (p *fileParser) startTag() {
+	name, hasAttr := p.z.TagName()
+	el := element{tag: atom.Lookup(name)}
+
+	var title, src, href []byte
+	var isTag bool
+	for hasAttr {
+		var key, val []byte
+		key, val, hasAttr = p.z.TagAttr()
+		switch string(key) {
+		case "class":
+			el.class = knownClass(val)
+		case "href":
+			href = val
+			if bytes.HasPrefix(val, []byte("tel:")) {
+				el.tel = string(val[len("tel:"):])
+			}
+		case "title":
+			title = val
+		case "src":
+			src = val
+		case "rel":
+			isTag = string(val) == "tag"
+		}
+	}
+
+	parentTel := ""
+	if len(p.stack) > 0 {
+		parentTel = p.stack[len(p.stack)-1].tel
+	}
+
+	// Like html.Parse, a self-closing slash on a non-void element is ignored.
+	void := voidElements[el.tag]
+	if !void {
+		p.stack = append(p.stack, el)
+	}
+	depth := len(p.stack)
+
+	switch el.tag {
+	case atom.Title:
+		p.startCapture(captureTitle, el, depth, "")
+	case atom.Div:
+		switch el.class {
+		case "hChatLog hfeed":
+			p.conv.Type = "chat"
+			p.chatDepth = depth
+		case "haudio":
+			p.conv = Conversation{}
+			p.call = callTypeSignals{file: callTypeFromFileName(p.filename)}
+			p.callTags = p.callTags[:0]
+			p.callDepth = depth
+		case "contributor vcard":
+			if p.callDepth > 0 {
+				p.conv.Participants = nil
+				p.vcardDepth = depth
+			}
+		case "message":
+			if p.chatDepth > 0 {
+				p.msg = Message{}
+				p.messageDepth = depth
+			}
+		case "tags":
+			p.conv.Labels = nil
+			p.tagsDepth = depth
+		}
+	case atom.Abbr:
+		switch {
+		case el.class == "dt" && p.messageDepth > 0:
+			if t, err := time.Parse(time.RFC3339, string(title)); err == nil {
+				p.msg.Timestamp = t
+			}
+		case el.class == "published" && p.callDepth > 0:
+			if title == nil {
+				p.lgr.Error("parse time err", "err", fmt.Errorf("no title attribute found for timestamp"))
+			} else if t, err := time.Parse(time.RFC3339, string(title)); err == nil {
+				p.conv.Timestamp = t
+			} else {
+				p.lgr.Error("parse time err", "err", err)
+			}
+		case el.class == "duration" && p.callDepth > 0:
+			p.startCapture(captureDuration, el, depth, "")
+		}
+	case atom.Span:
+		if el.class == "full-text" && p.callDepth > 0 {
+			p.call.transcript = true
+			p.startCapture(captureTranscript, el, depth, "")
+		}
+	case atom.Cite:
+		if p.messageDepth > 0 {
+			p.citeDepth = depth
+		}
+	case atom.A:
+		if p.citeDepth > 0 && el.tel != "" {
+			p.msg.SenderNumber = el.tel
+		}
+		if p.tagsDepth > 0 && isTag {
+			if p.callDepth > 0 {
+				p.callTags = append(p.callTags, tagFragment(string(href)))
+			}
+			p.startCapture(captureLabel, el, depth, "")
+		}
+	case atom.Q:
+		if p.messageDepth > 0 {
+			p.startCapture(captureContent, el, depth, "")
+		}
+	case atom.Audio:
+		if p.callDepth > 0 && src != nil {
+			p.conv.Audio = string(src)
+		}
+	case atom.Img:
+		if p.messageDepth > 0 && src != nil {
+			p.msg.Images = append(p.msg.Images, string(src))
+		}
+	}
+
+	if el.class == "fn" && !void && (p.chatDepth > 0 || p.vcardDepth > 0) {
+		p.startCapture(captureName, el, depth, parentTel)
+	}
+}
+
+// knownClass returns the class names the parser acts on, avoiding an
+// allocation for every other class attribute.
+func knownClass(val []byte) string {
+	switch string(val) {
+	case "hChatLog hfeed":
+		return "hChatLog hfeed"
+	case "haudio":
+		return "haudio"
+	case "contributor vcard":
+		return "contributor vcard"
+	case "message":
+		return "message"
+	case "tags":
+		return "tags"
+	case "dt":
+		return "dt"
+	case "published":
+		return "published"
+	case "duration":
+		return "duration"
+	case "full-text":
+		return "full-text"
+	case "fn":
+		return "fn"
+	}
+	return ""
+}
+
+func (p *fileParser) startCapture(c capture, el element, depth int, tel string) {
+	if p.capture != captureNone {
+		return
+	}
+	p.capture = c
+	p.captureDepth = depth
+	p.captureTag = el.tag
+	p.captureTel = tel
+	p.text.Reset()
+}
+
+func (p *fileParser) endTag() {
+	name, _ := p.z.TagName()
+	tag := atom.Lookup(name)
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if p.stack[i].tag == tag {
+			for len(p.stack) > i {
+				p.pop()
+			}
+			return
+		}
+	}
+}
+
+func (p *fileParser) pop() {
+	depth := len(p.stack)
+
+	if p.capture != captureNone && depth == p.captureDepth {
+		p.finishCapture()
+	}
+
+	switch depth {
+	case p.messageDepth:
+		p.conv.Messages = append(p.conv.Messages, p.msg)
+		p.messageDepth = 0
+	case p.citeDepth:
+		p.citeDepth = 0
+	case p.vcardDepth:
+		p.vcardDepth = 0
+	case p.tagsDepth:
+		p.tagsDepth = 0
+	case p.chatDepth:
+		p.chatDepth = 0
+	case p.callDepth:
+		p.call.tag = callTypeFromTags(p.callTags)
+		p.conv.Type = p.call.resolve()
+		if p.conv.Type == unknownCallType {
+			p.lgr.Warn("unrecognized call type, keeping it as " + unknownCallType)
+		}
+		p.callDepth = 0
+	}
+
+	p.stack = p.stack[:depth-1]
+}
+
+func (p *fileParser) handleText(text []byte) {
+	if p.capture != captureNone {
+		p.text.Write(text)
+	}
+
+	// The last text naming a call type wins, as it always has.
+	if p.callDepth > 0 {
+		if typ := callTypeFromText(text); typ != "" {
+			p.call.text = typ
+		}
+	}
+}
+
+func (p *fileParser) finishCapture() {
+	text := strings.TrimSpace(p.text.String())
+	p.text.Reset()
+
+	switch p.capture {
+	case captureTitle:
+		title := strings.ReplaceAll(text, "\n", " ")
+		parts := strings.Split(title, " to ")
+
+		if len(parts) == 2 {
+			p.conv.Participants.add(Participant{Name: strings.TrimSpace(parts[0]), Source: participantFromTitle})
+			p.conv.Participants.add(Participant{Name: strings.TrimSpace(parts[1]), Source: participantFromTitle})
+		}
+	case captureName:
+		source := participantFromGroup
+		switch {
+		case p.vcardDepth > 0:
+			source = participantFromCall
+		case p.citeDepth > 0:
+			source = participantFromMessage
+		}
+		p.conv.Participants.add(Participant{Name: text, Number: p.captureTel, Source: source})
+		if p.citeDepth > 0 && (p.captureTag == atom.Abbr || p.captureTag == atom.Span) {
+			p.msg.Sender = text
+		}
+	case captureContent:
+		p.msg.Content = text
+	case captureTranscript:
+		p.conv.Transcript = text
+	case captureDuration:
+		p.conv.Duration = strings.Trim(text, "()")
+	case captureLabel:
+		p.conv.Labels = append(p.conv.Labels, text)
+	}
+
+	p.capture = captureNone
+}
+
+var errNoMediaFile = errors.New("no matching media file found")
+
+func findMediaFile(relativePath string) (string, error) {
+	if strings.TrimSpace(relativePath) == "" {
+		// An empty name would match every file.
+		return "", fmt.Errorf("%w for an unnamed attachment", errNoMediaFile)
+	}
+	parts := strings.Split(relativePath, " ")
+	last := parts[len(parts)-1]
+
+	look := func(glob string) (string, error) {
+		matches, err := filepath.Glob("*" + glob + "*")
+		if err != nil {
+			return "", err
+		}
+		for _, match := range matches {
+			if strings.HasSuffix(match, ".html") {
+				continue
+			}
+			return match, nil
+		}
+		return "", nil
+	}
+
+	match, err := look(last)
+	if err != nil {
+		return "", err
+	}
+	if match != "" {
+		return match, nil
+	}
+
+	lastParts := strings.Split(last, "-")
+	if len(lastParts) > 2 {
+		lastParts = lastParts[:len(lastParts)-1]
+		last = strings.Join(lastParts, "-")
+
+		match, err := look(last)
+		if err != nil {
+			return "", err
+		}
+		if match != "" {
+			return match, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w for %s", errNoMediaFile, relativePath)
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// parseFileDOM is the original html.Parse based implementation of parseFile.
+// It is kept as a reference for the equivalence test and benchmarks in
+// parse_test.go.
+func parseFileDOM(lgr *slog.Logger, r io.Reader, filename string) (Conversation, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return Conversation{}, err
+	}
+
+	var conversation Conversation
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "title":
+				title := domExtractTitle(n)
+				title = strings.ReplaceAll(title, "\n", " ")
+				parts := strings.Split(title, " to ")
+
+				if len(parts) == 2 {
+					conversation.Participants.add(Participant{Name: strings.TrimSpace(parts[0]), Source: participantFromTitle})
+					conversation.Participants.add(Participant{Name: strings.TrimSpace(parts[1]), Source: participantFromTitle})
+				}
+
+			case "div":
+				for _, a := range n.Attr {
+					if a.Key == "class" {
+						switch a.Val {
+						case "hChatLog hfeed":
+							conversation.Type = "chat"
+							domParseParticipants(n, participantFromGroup, &conversation.Participants)
+							conversation.Messages = domParseMessages(lgr, n)
+							conversation.linkReactions()
+							if len(conversation.Messages) > 0 {
+								conversation.Timestamp = conversation.Messages[0].Timestamp
+							}
+						case "haudio":
+							conversation = domParseCallOrVoicemail(lgr, n, filename)
+						case "tags":
+							conversation.Labels = domParseLabels(n)
+						}
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	return conversation, nil
+}
+
+func domParseCallOrVoicemail(lgr *slog.Logger, n *html.Node, filename string) Conversation {
+	var conv Conversation
+	signals := callTypeSignals{file: callTypeFromFileName(filename)}
+	var tags []string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "div":
+				for _, a := range n.Attr {
+					if a.Key == "class" && a.Val == "contributor vcard" {
+						conv.Participants = nil
+						domParseParticipants(n, participantFromCall, &conv.Participants)
+					}
+				}
+			case "span":
+				for _, a := range n.Attr {
+					if a.Key == "class" && a.Val == "full-text" {
+						conv.Transcript = domExtractText(n)
+						signals.transcript = true
+					}
+				}
+			case "audio":
+				for _, a := range n.Attr {
+					if a.Key == "src" {
+						conv.Audio = a.Val
+					}
+				}
+			case "a":
+				var isTag bool
+				var href string
+				for _, a := range n.Attr {
+					switch a.Key {
+					case "rel":
+						isTag = a.Val == "tag"
+					case "href":
+						href = a.Val
+					}
+				}
+				if isTag {
+					tags = append(tags, tagFragment(href))
+				}
+			case "abbr":
+				for _, a := range n.Attr {
+					if a.Key == "class" {
+						switch a.Val {
+						case "published":
+							if t, err := domParseTimestamp(n); err == nil {
+								conv.Timestamp = t
+							} else {
+								lgr.Error("parse time err", "err", err)
+							}
+						case "duration":
+							conv.Duration = strings.Trim(domExtractText(n), "()")
+						}
+					}
+				}
+			}
+		} else if n.Type == html.TextNode && signals.text == "" {
+			signals.text = callTypeFromText([]byte(n.Data))
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	signals.tag = callTypeFromTags(tags)
+	conv.Type = signals.resolve()
+	return conv
+}
+
+func domParseLabels(n *html.Node) []string {
+	var labels []string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, a := range n.Attr {
+				if a.Key == "rel" && a.Val == "tag" {
+					labels = append(labels, domExtractText(n))
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return labels
+}
+
+func domParseTimestamp(n *html.Node) (time.Time, error) {
+	for _, a := range n.Attr {
+		if a.Key == "title" {
+			return time.Parse(time.RFC3339, a.Val)
+		}
+	}
+	return time.Time{}, fmt.Errorf("no title attribute found for timestamp")
+}
+
+// domParseParticipants adds the names under n to participants. Names inside
+// a message are its sender; the rest have the given source.
+func domParseParticipants(n *html.Node, source string, participants *participantList) {
+	var f func(*html.Node, string)
+	f = func(n *html.Node, source string) {
+		if n.Type == html.ElementNode {
+			for _, a := range n.Attr {
+				if a.Key == "class" && a.Val == "message" && n.Data == "div" {
+					source = participantFromMessage
+				}
+				if a.Key == "class" && a.Val == "fn" {
+					name := domExtractText(n)
+					number := domExtractPhoneNumber(n.Parent)
+					participants.add(Participant{Name: name, Number: number, Source: source})
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c, source)
+		}
+	}
+	f(n, source)
+}
+
+func domExtractPhoneNumber(n *html.Node) string {
+	for _, a := range n.Attr {
+		if a.Key == "href" && strings.HasPrefix(a.Val, "tel:") {
+			return strings.TrimPrefix(a.Val, "tel:")
+		}
+	}
+	return ""
+}
+
+func domExtractTitle(n *html.Node) string {
+	var title string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "title" {
+			title = domExtractText(n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return title
+}
+
+func domParseMessages(lgr *slog.Logger, n *html.Node) []Message {
+	var messages []Message
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "div" {
+			for _, a := range n.Attr {
+				if a.Key == "class" && a.Val == "message" {
+					msg := domParseMessage(n)
+					messages = append(messages, msg)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return messages
+}
+
+func domParseMessage(n *html.Node) Message {
+	var msg Message
+	var senderName, senderNumber string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "abbr":
+				for _, a := range n.Attr {
+					if a.Key == "class" && a.Val == "dt" {
+						msg.Timestamp = domParseMessageTimestamp(n)
+					}
+				}
+			case "cite":
+				senderName, senderNumber = domParseSenderAndNumber(n)
+			case "q":
+				msg.Content = domExtractText(n)
+			case "img":
+				for _, a := range n.Attr {
+					if a.Key == "src" {
+						msg.Images = append(msg.Images, a.Val)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	msg.Sender = senderName
+	msg.SenderNumber = senderNumber
+	return msg
+}
+
+func domParseSenderAndNumber(n *html.Node) (string, string) {
+	var sender, number string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "abbr", "span":
+				for _, a := range n.Attr {
+					if a.Key == "class" && a.Val == "fn" {
+						sender = domExtractText(n)
+					}
+				}
+			case "a":
+				for _, a := range n.Attr {
+					if a.Key == "href" && strings.HasPrefix(a.Val, "tel:") {
+						number = strings.TrimPrefix(a.Val, "tel:")
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return sender, number
+}
+
+func domParseMessageTimestamp(n *html.Node) time.Time {
+	for _, a := range n.Attr {
+		if a.Key == "title" {
+			if t, err := time.Parse(time.RFC3339, a.Val); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func domExtractText(n *html.Node) string {
+	var text string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text += n.Data
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return strings.TrimSpace(text)
+}
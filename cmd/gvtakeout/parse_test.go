@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// largeThread returns mms.html with its messages repeated to simulate a
+// multi-megabyte thread file.
+func largeThread(tb testing.TB, repeat int) []byte {
+	tb.Helper()
+
+	input, err := os.ReadFile("testdata/mms.html")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s := string(input)
+
+	start := strings.Index(s, `<div class="message">`)
+	end := strings.LastIndex(s, "</div></div>\n<div class=\"tags\">")
+	if start < 0 || end < 0 {
+		tb.Fatal("unexpected mms.html layout")
+	}
+	end += len("</div>")
+
+	var buf bytes.Buffer
+	buf.WriteString(s[:start])
+	for i := 0; i < repeat; i++ {
+		buf.WriteString(s[start:end])
+		buf.WriteString(" ")
+	}
+	buf.WriteString(s[end:])
+	return buf.Bytes()
+}
+
+func TestParseFileMatchesDOM(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	localeFiles, err := filepath.Glob("testdata/locales/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, localeFiles...)
+
+	inputs := make(map[string][]byte)
+	for _, file := range files {
+		input, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs[file] = input
+	}
+	inputs["large thread"] = largeThread(t, 50)
+
+	lgr := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for name, input := range inputs {
+		streamed, err := parseFile(lgr, bytes.NewReader(input), name)
+		if err != nil {
+			t.Fatalf("%s: parseFile: %v", name, err)
+		}
+		dom, err := parseFileDOM(lgr, bytes.NewReader(input), name)
+		if err != nil {
+			t.Fatalf("%s: parseFileDOM: %v", name, err)
+		}
+
+		got, err := json.Marshal(streamed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := json.Marshal(dom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: streaming parser differs from DOM parser\ngot:  %s\nwant: %s", name, got, want)
+		}
+	}
+}
+
+func benchmarkParse(b *testing.B, parse func(*slog.Logger, io.Reader, string) (Conversation, error)) {
+	input := largeThread(b, 5000)
+	lgr := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		conv, err := parse(lgr, bytes.NewReader(input), "large.html")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(conv.Messages) != 6*5000 {
+			b.Fatalf("Expected %d messages, got %d", 6*5000, len(conv.Messages))
+		}
+	}
+}
+
+func BenchmarkParseFile(b *testing.B) {
+	benchmarkParse(b, parseFile)
+}
+
+func BenchmarkParseFileDOM(b *testing.B) {
+	benchmarkParse(b, parseFileDOM)
+}
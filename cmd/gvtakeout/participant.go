@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// Participant is someone in a conversation. Source says where the parser
+// found them: the file's title, the participant list of a group
+// conversation, the sender of a message, the contact of a call, or the
+// thread of an Android backup. An entry first seen in the title takes the
+// source of wherever its number is found later, so a "title" participant is
+// one the file gives no number for.
+type Participant struct {
+	ContactID string `json:"contact_id"`
+	Name      string `json:"name"`
+	Number    string `json:"number"`
+	IsSelf    bool   `json:"is_self,omitempty"`
+	Source    string `json:"source"`
+}
+
+const (
+	participantFromTitle   = "title"
+	participantFromGroup   = "group"
+	participantFromMessage = "message"
+	participantFromCall    = "call"
+	participantFromAddress = "address"
+)
+
+// selfName is how takeouts name the account owner.
+const selfName = "Me"
+
+// participantList holds a conversation's participants in the order they
+// first appear in the file. Two contacts with the same name but different
+// numbers are kept apart.
+type participantList []Participant
+
+// add records a participant. Someone already listed under the same name is
+// updated rather than added again when either entry has no number, so the
+// names in a title are filled in once their numbers are seen.
+func (l *participantList) add(p Participant) {
+	if p.Name == selfName {
+		p.IsSelf = true
+	}
+	for i, q := range *l {
+		if q.Name != p.Name {
+			continue
+		}
+		if q.Number == p.Number || p.Number == "" {
+			return
+		}
+		if q.Number == "" {
+			(*l)[i].Number = p.Number
+			(*l)[i].Source = p.Source
+			return
+		}
+	}
+	*l = append(*l, p)
+}
+
+// find returns the index of the participant who sent a message, matching
+// on name and number and falling back to the name alone, or -1.
+func (l participantList) find(name, number string) int {
+	byName := -1
+	for i, p := range l {
+		if p.Name != name {
+			continue
+		}
+		if p.Number == number {
+			return i
+		}
+		if byName < 0 {
+			byName = i
+		}
+	}
+	return byName
+}
+
+// markSelf flags the participants whose number is the account's.
+func (l participantList) markSelf(account string) {
+	if account == "" {
+		return
+	}
+	for i := range l {
+		if l[i].Number == account {
+			l[i].IsSelf = true
+		}
+	}
+}
+
+// legacyMap returns the participants in the {"name": "number"} form the json
+// format used before participants were a list. Participants sharing a name
+// collapse into one entry.
+func (l participantList) legacyMap() map[string]string {
+	m := make(map[string]string, len(l))
+	for _, p := range l {
+		m[p.Name] = p.Number
+	}
+	return m
+}
+
+func (l participantList) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Participant(l))
+}
+
+// UnmarshalJSON accepts the legacy {"name": "number"} form as well as a list,
+// so older json output can still be read back. Legacy entries are sorted by
+// name since the object's order carries no meaning.
+func (l *participantList) UnmarshalJSON(data []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return json.Unmarshal(data, (*[]Participant)(l))
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	*l = (*l)[:0]
+	for _, name := range names {
+		l.add(Participant{Name: name, Number: m[name]})
+	}
+	return nil
+}
+
+// legacyConversation is a Conversation as the json format wrote it with
+// -legacy-participants.
+type legacyConversation struct {
+	Conversation
+	Participants map[string]string `json:"participants"`
+}
+
+func newLegacyConversation(conv Conversation) legacyConversation {
+	return legacyConversation{Conversation: conv, Participants: conv.Participants.legacyMap()}
+}
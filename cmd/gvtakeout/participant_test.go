@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParticipantListAdd(t *testing.T) {
+	var l participantList
+	l.add(Participant{Name: "Me", Source: participantFromTitle})
+	l.add(Participant{Name: "Tony Smehrik", Source: participantFromTitle})
+	l.add(Participant{Name: "Tony Smehrik", Number: "+333", Source: participantFromMessage})
+	l.add(Participant{Name: "Tony Smehrik", Number: "+444", Source: participantFromMessage})
+	l.add(Participant{Name: "Tony Smehrik", Number: "+333", Source: participantFromMessage})
+	l.add(Participant{Name: "Tony Smehrik", Source: participantFromTitle})
+	l.markSelf("+2222")
+
+	want := participantList{
+		{Name: "Me", IsSelf: true, Source: participantFromTitle},
+		{Name: "Tony Smehrik", Number: "+333", Source: participantFromMessage},
+		{Name: "Tony Smehrik", Number: "+444", Source: participantFromMessage},
+	}
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("Expected %+v, got %+v", want, l)
+	}
+	if i := l.find("Tony Smehrik", "+444"); i != 2 {
+		t.Errorf("Expected the second Tony Smehrik, got %d", i)
+	}
+	if i := l.find("Tony Smehrik", ""); i != 1 {
+		t.Errorf("Expected a name-only match on the first Tony Smehrik, got %d", i)
+	}
+}
+
+func TestParticipantListJSON(t *testing.T) {
+	var conv Conversation
+	if err := json.Unmarshal([]byte(`{"participants":{"Tony Smehrik":"+333","Me":"+2222"}}`), &conv); err != nil {
+		t.Fatal(err)
+	}
+	want := participantList{
+		{Name: "Me", Number: "+2222", IsSelf: true},
+		{Name: "Tony Smehrik", Number: "+333"},
+	}
+	if !reflect.DeepEqual(conv.Participants, want) {
+		t.Errorf("Expected legacy participants to decode as %+v, got %+v", want, conv.Participants)
+	}
+
+	var buf bytes.Buffer
+	w, err := outputWriters["json"](OutputOptions{Stdout: &buf, LegacyParticipants: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(conv); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"participants":{"Me":"+2222","Tony Smehrik":"+333"}`) {
+		t.Errorf("Expected legacy participants in output, got %s", buf.String())
+	}
+
+	out, err := json.Marshal(Conversation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"participants":[]`) {
+		t.Errorf("Expected an empty participant list, got %s", out)
+	}
+}
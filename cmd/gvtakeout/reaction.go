@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Reaction is a tapback on a message. Google Voice stores these as ordinary
+// texts, e.g. Liked “Hahahaha”, which the parser folds into the message they
+// react to.
+type Reaction struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Sender       string    `json:"sender"`
+	SenderNumber string    `json:"sender_number"`
+	Kind         string    `json:"kind"`
+}
+
+// reactionKinds maps the verb phones use when sending a reaction as a text
+// to the kind of reaction, and gives the emoji shown for it.
+var reactionKinds = []struct {
+	verb    string
+	removal string
+	kind    string
+	emoji   string
+}{
+	{"Liked", "a like", "like", "👍"},
+	{"Loved", "a heart", "love", "❤️"},
+	{"Disliked", "a dislike", "dislike", "👎"},
+	{"Laughed at", "a laugh", "laugh", "😂"},
+	{"Emphasized", "an exclamation", "emphasize", "‼️"},
+	{"Questioned", "a question mark", "question", "❓"},
+}
+
+// reactionAttachments are how a reaction refers to a message with an
+// attachment instead of quoting its text.
+var reactionAttachments = []string{"an image", "a photo", "a video", "an attachment", "a movie"}
+
+// reactionText is a message parsed as a reaction.
+type reactionText struct {
+	kind string
+	// quote is the text of the message reacted to. It is empty when the
+	// reaction is to an attachment.
+	quote   string
+	removed bool
+}
+
+// parseReaction reports whether content is a reaction such as
+// Loved “see you soon”, Liked an image or Removed a heart from “see you soon”.
+func parseReaction(content string) (reactionText, bool) {
+	for _, k := range reactionKinds {
+		var r reactionText
+		rest, ok := strings.CutPrefix(content, k.verb+" ")
+		if !ok {
+			rest, ok = strings.CutPrefix(content, "Removed "+k.removal+" from ")
+			r.removed = true
+		}
+		if !ok {
+			continue
+		}
+		r.kind = k.kind
+		for _, a := range reactionAttachments {
+			if rest == a {
+				return r, true
+			}
+		}
+		for _, q := range [][2]string{{"“", "”"}, {`"`, `"`}} {
+			if len(rest) > len(q[0])+len(q[1]) && strings.HasPrefix(rest, q[0]) && strings.HasSuffix(rest, q[1]) {
+				r.quote = rest[len(q[0]) : len(rest)-len(q[1])]
+				return r, true
+			}
+		}
+	}
+	return reactionText{}, false
+}
+
+// reactionEmoji returns the emoji for a kind of reaction.
+func reactionEmoji(kind string) string {
+	for _, k := range reactionKinds {
+		if k.kind == kind {
+			return k.emoji
+		}
+	}
+	return kind
+}
+
+// describeReactions summarizes a message's reactions for terminal output,
+// e.g. "[love from Tony Smehrik, like from Me]".
+func describeReactions(reactions []Reaction) string {
+	parts := make([]string, len(reactions))
+	for i, r := range reactions {
+		parts[i] = r.Kind + " from " + r.Sender
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// matches reports whether m is the message r reacts to. Phones shorten long
+// quotes with an ellipsis.
+func (r reactionText) matches(m Message) bool {
+	if r.quote == "" {
+		return len(m.Images) > 0
+	}
+	if m.Content == r.quote {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(r.quote, "…")
+	return ok && prefix != "" && strings.HasPrefix(m.Content, prefix)
+}
+
+// linkReactions moves reaction texts onto the most recent earlier message
+// they quote. A reaction whose message isn't in the conversation is left as
+// an ordinary message.
+func (c *Conversation) linkReactions() {
+	var msgs []Message
+	for _, m := range c.Messages {
+		r, ok := parseReaction(m.Content)
+		if !ok || len(m.Images) > 0 {
+			msgs = append(msgs, m)
+			continue
+		}
+		target := -1
+		for i := len(msgs) - 1; i >= 0; i-- {
+			if r.matches(msgs[i]) {
+				target = i
+				break
+			}
+		}
+		if target < 0 {
+			msgs = append(msgs, m)
+			continue
+		}
+
+		t := &msgs[target]
+		if !r.removed {
+			t.Reactions = append(t.Reactions, Reaction{Timestamp: m.Timestamp, Sender: m.Sender, SenderNumber: m.SenderNumber, Kind: r.kind})
+			continue
+		}
+		for i := len(t.Reactions) - 1; i >= 0; i-- {
+			if t.Reactions[i].Kind == r.kind && t.Reactions[i].Sender == m.Sender && t.Reactions[i].SenderNumber == m.SenderNumber {
+				t.Reactions = append(t.Reactions[:i:i], t.Reactions[i+1:]...)
+				break
+			}
+		}
+		if len(t.Reactions) == 0 {
+			t.Reactions = nil
+		}
+	}
+	c.Messages = msgs
+}
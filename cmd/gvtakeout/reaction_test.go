@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReaction(t *testing.T) {
+	tests := []struct {
+		content string
+		want    reactionText
+		ok      bool
+	}{
+		{"Liked “Hahahaha”", reactionText{kind: "like", quote: "Hahahaha"}, true},
+		{`Laughed at "see you soon"`, reactionText{kind: "laugh", quote: "see you soon"}, true},
+		{"Loved an image", reactionText{kind: "love"}, true},
+		{"Removed a question mark from “what time?”", reactionText{kind: "question", quote: "what time?", removed: true}, true},
+		{"Liked it a lot", reactionText{}, false},
+		{"Loved “”", reactionText{}, false},
+		{"I Liked “Hahahaha”", reactionText{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseReaction(tt.content)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseReaction(%q) = %+v, %v; expected %+v, %v", tt.content, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLinkReactions(t *testing.T) {
+	conv := parseTestdata(t, "reactions.html")[0]
+
+	if len(conv.Messages) != 4 {
+		t.Fatalf("Expected reactions to be folded into 4 messages, got %+v", conv.Messages)
+	}
+	first := conv.Messages[0]
+	if len(first.Reactions) != 1 || first.Reactions[0].Kind != "love" || first.Reactions[0].Sender != "Tony Smehrik" {
+		t.Errorf("Expected the removed like to leave only a love on a quoted-with-ellipsis message, got %+v", first.Reactions)
+	}
+	image := conv.Messages[1]
+	if len(image.Images) != 1 || len(image.Reactions) != 1 || image.Reactions[0].Kind != "laugh" {
+		t.Errorf("Expected a laugh on the image, got %+v", image)
+	}
+	if unlinked := conv.Messages[2]; unlinked.Content != "Liked “the one by the station”" || unlinked.Reactions != nil {
+		t.Errorf("Expected a reaction to a missing message to stay a message, got %+v", unlinked)
+	}
+
+	loc := time.FixedZone("UTC+1", 60*60)
+	if got := conv.In(loc).Messages[0].Reactions[0].Timestamp.Location(); got != loc {
+		t.Errorf("Expected In to convert reaction timestamps, got %v", got)
+	}
+
+	var buf bytes.Buffer
+	printConversation(&buf, conv, palette{})
+	if !strings.Contains(buf.String(), "[love from Tony Smehrik]") {
+		t.Errorf("Expected reactions in show output, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// runRedact implements the redact command, which produces a takeout or
+// parser output that is safe to share: names and numbers are replaced with
+// consistent pseudonyms, message text is replaced, and media is emptied.
+func runRedact(args []string) error {
+	fs, common := newFlagSet("redact", "")
+	out := fs.String("out", "redacted", "Directory to write the redacted takeout to")
+	textMode := fs.String("text", "lorem", "How to replace message text: lorem (lorem ipsum of equal length) or hash")
+	key := fs.String("key", "", "Key for -text=hash; runs with the same key hash the same text alike (default: a random key per run)")
+	jsonMode := fs.Bool("json", false, "Redact parser JSON output read from stdin instead of the takeout in the current directory")
+	fs.Parse(args)
+
+	if _, err := common.setup(); err != nil {
+		return err
+	}
+
+	r, err := newRedactor(*textMode, *key)
+	if err != nil {
+		return err
+	}
+
+	if *jsonMode {
+		if err := r.redactJSON(os.Stdin, os.Stdout); err != nil {
+			return fmt.Errorf("redact JSON failed: %w", err)
+		}
+		return nil
+	}
+
+	if err := r.redactTakeout(slog.Default(), ".", *out); err != nil {
+		return fmt.Errorf("redact takeout failed: %w", err)
+	}
+	return nil
+}
+
+// redactor assigns pseudonyms and replacement text. Pseudonyms are handed
+// out in the order names and numbers are first seen, so the same input
+// always produces the same output. Hashed text is only reproduced across
+// runs given the same key.
+type redactor struct {
+	textMode string
+	hashKey  []byte
+
+	names   map[string]string
+	numbers map[string]string
+
+	// replacements holds the names and numbers replaceNames looks for,
+	// keyed by first byte and longest first.
+	replacements map[byte][]replacement
+}
+
+type replacement struct {
+	old, new string
+}
+
+// newRedactor returns a redactor replacing text as textMode says. An empty
+// hashKey picks a random one.
+func newRedactor(textMode, hashKey string) (*redactor, error) {
+	if textMode != "lorem" && textMode != "hash" {
+		return nil, fmt.Errorf("invalid -text %q, must be lorem or hash", textMode)
+	}
+
+	// Hashes are keyed so short messages can't be recovered by hashing a
+	// dictionary, while repeats within one output still match.
+	key := []byte(hashKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return &redactor{
+		textMode: textMode,
+		hashKey:  key,
+		names:    make(map[string]string),
+		numbers:  make(map[string]string),
+	}, nil
+}
+
+// name returns the pseudonym for a display name. "Me" is kept since it
+// identifies the account owner rather than a person.
+func (r *redactor) name(n string) string {
+	n = strings.TrimSpace(n)
+	if n == "" || n == selfName {
+		return n
+	}
+	if looksLikePhoneNumber(n) {
+		return r.number(n)
+	}
+	p, ok := r.names[n]
+	if !ok {
+		p = fmt.Sprintf("Contact %d", len(r.names)+1)
+		r.names[n] = p
+		r.replacements = nil
+	}
+	return p
+}
+
+// number returns the pseudonym for a phone number, +1 555 and a counter.
+// 555 is not an area code in use, so it can't collide with a real number.
+func (r *redactor) number(n string) string {
+	n = strings.TrimSpace(n)
+	if n == "" {
+		return n
+	}
+	p, ok := r.numbers[n]
+	if !ok {
+		p = fmt.Sprintf("+1555%07d", len(r.numbers)+1)
+		r.numbers[n] = p
+		r.replacements = nil
+	}
+	return p
+}
+
+// text replaces message content.
+func (r *redactor) text(s string) string {
+	if s == "" {
+		return s
+	}
+	if r.textMode == "hash" {
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(s))
+		return hex.EncodeToString(mac.Sum(nil))[:16]
+	}
+	return loremOfLength(utf8.RuneCountInString(s))
+}
+
+// replaceNames replaces every known name and number appearing as whole
+// words in free text such as titles and file names, so a contact named
+// "Al" leaves "Alabama" alone.
+func (r *redactor) replaceNames(s string) string {
+	if r.replacements == nil {
+		r.replacements = make(map[byte][]replacement)
+		add := func(m map[string]string) {
+			for orig, p := range m {
+				r.replacements[orig[0]] = append(r.replacements[orig[0]], replacement{orig, p})
+			}
+		}
+		add(r.names)
+		add(r.numbers)
+		// Prefer the longest match so "Tony Smehrik" wins over "Tony".
+		for _, rs := range r.replacements {
+			sort.Slice(rs, func(i, j int) bool {
+				if len(rs[i].old) != len(rs[j].old) {
+					return len(rs[i].old) > len(rs[j].old)
+				}
+				return rs[i].old < rs[j].old
+			})
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if i == 0 || !isWordRune(lastRune(s[:i])) {
+			matched := false
+			for _, rp := range r.replacements[s[i]] {
+				end := i + len(rp.old)
+				if strings.HasPrefix(s[i:], rp.old) && (end == len(s) || !isWordRune(firstRune(s[end:]))) {
+					b.WriteString(rp.new)
+					i = end
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(s[i : i+size])
+		i += size
+	}
+	return b.String()
+}
+
+func isWordRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func firstRune(s string) rune {
+	c, _ := utf8.DecodeRuneInString(s)
+	return c
+}
+
+func lastRune(s string) rune {
+	c, _ := utf8.DecodeLastRuneInString(s)
+	return c
+}
+
+const lorem = "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. "
+
+func loremOfLength(n int) string {
+	var b strings.Builder
+	for b.Len() < n {
+		b.WriteString(lorem)
+	}
+	return strings.TrimSpace(b.String()[:n-1] + ".")
+}
+
+func looksLikePhoneNumber(s string) bool {
+	digits := 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case strings.ContainsRune("+-() .", c):
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// registerFileName registers the contact a takeout file is named after, e.g.
+// "Tony Smehrik - Text - 2022-07-01T01_06_39Z.html". This catches contacts
+// that only appear in media file names.
+func (r *redactor) registerFileName(name string) {
+	prefix, _, found := strings.Cut(name, " - ")
+	if !found || prefix == "Group Conversation" {
+		return
+	}
+	r.name(prefix)
+}
+
+func (r *redactor) registerConversation(conv Conversation) {
+	for _, p := range conv.Participants {
+		r.name(p.Name)
+		r.number(p.Number)
+	}
+	for _, m := range conv.Messages {
+		r.name(m.Sender)
+		r.number(m.SenderNumber)
+	}
+}
+
+func (r *redactor) redactConversation(conv Conversation) Conversation {
+	r.registerConversation(conv)
+
+	if conv.Participants != nil {
+		participants := make(participantList, len(conv.Participants))
+		for i, p := range conv.Participants {
+			p.Name = r.name(p.Name)
+			p.Number = r.number(p.Number)
+			participants[i] = p
+		}
+		conv.Participants = participants
+	}
+
+	msgs := make([]Message, len(conv.Messages))
+	for i, m := range conv.Messages {
+		m.Sender = r.name(m.Sender)
+		m.SenderNumber = r.number(m.SenderNumber)
+		m.Content = r.text(m.Content)
+		if m.Reactions != nil {
+			reactions := make([]Reaction, len(m.Reactions))
+			for j, rc := range m.Reactions {
+				rc.Sender = r.name(rc.Sender)
+				rc.SenderNumber = r.number(rc.SenderNumber)
+				reactions[j] = rc
+			}
+			m.Reactions = reactions
+		}
+		if m.Images != nil {
+			images := make([]string, len(m.Images))
+			for j, img := range m.Images {
+				images[j] = r.replaceNames(img)
+			}
+			m.Images = images
+		}
+		msgs[i] = m
+	}
+	if conv.Messages != nil {
+		conv.Messages = msgs
+	}
+
+	conv.Transcript = r.text(conv.Transcript)
+	conv.SourceFile = r.replaceNames(conv.SourceFile)
+	if conv.CallEvent != nil {
+		ev := *conv.CallEvent
+		ev.Number = r.number(ev.Number)
+		ev.MissedCall.SourceFile = r.replaceNames(ev.MissedCall.SourceFile)
+		ev.Voicemail.SourceFile = r.replaceNames(ev.Voicemail.SourceFile)
+		conv.CallEvent = &ev
+	}
+	if conv.Account != "" {
+		conv.Account = r.number(conv.Account)
+	}
+	if conv.ID != "" {
+		// The IDs hash the names and numbers they were made from.
+		conv.assignIDs()
+	}
+	return conv
+}
+
+// redactJSON redacts newline delimited conversations as written by the json
+// output format.
+func (r *redactor) redactJSON(in io.Reader, out io.Writer) error {
+	dec := json.NewDecoder(bufio.NewReader(in))
+	enc := json.NewEncoder(out)
+	for {
+		var conv Conversation
+		err := dec.Decode(&conv)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := enc.Encode(r.redactConversation(conv)); err != nil {
+			return err
+		}
+	}
+}
+
+// redactTakeout writes a redacted copy of the takeout in dir to outDir. HTML
+// files are rewritten so they still parse with parseFile; every other file
+// is written empty under its redacted name so attachments still resolve.
+func (r *redactor) redactTakeout(lgr *slog.Logger, dir, outDir string) error {
+	inAbs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	outAbs, err := filepath.Abs(outDir)
+	if err != nil {
+		return err
+	}
+	if inAbs == outAbs {
+		return errors.New("output directory must differ from the takeout directory")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var htmlFiles, mediaFiles []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(e.Name(), ".html") {
+			htmlFiles = append(htmlFiles, e.Name())
+		} else {
+			mediaFiles = append(mediaFiles, e.Name())
+		}
+	}
+
+	// Learn every name before rewriting anything so the replacements used
+	// in titles and file names are complete.
+	for _, name := range htmlFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		conv, err := parseFile(lgr.With("file", name), f, name)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", name, err)
+		}
+		r.registerConversation(conv)
+		r.registerFileName(name)
+	}
+	for _, name := range mediaFiles {
+		r.registerFileName(name)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+
+	for _, name := range htmlFiles {
+		if err := r.redactHTMLFile(filepath.Join(dir, name), filepath.Join(outDir, r.replaceNames(name))); err != nil {
+			return fmt.Errorf("redact %s: %w", name, err)
+		}
+	}
+
+	for _, name := range mediaFiles {
+		if err := os.WriteFile(filepath.Join(outDir, r.replaceNames(name)), nil, 0644); err != nil {
+			return err
+		}
+	}
+
+	lgr.Info("wrote redacted takeout", "dir", outDir, "html_files", len(htmlFiles), "media_files", len(mediaFiles))
+	return nil
+}
+
+func (r *redactor) redactHTMLFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(out)
+	if err := r.redactHTML(in, w); err != nil {
+		out.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// rawTextElements hold text that HTML doesn't escape.
+var rawTextElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Xmp: true, atom.Iframe: true,
+	atom.Noembed: true, atom.Noframes: true,
+}
+
+// redactHTML copies a takeout HTML file token by token. Message and
+// transcript text is replaced, names in other text and in attributes are
+// swapped for pseudonyms, and everything else is copied verbatim.
+func (r *redactor) redactHTML(in io.Reader, out io.Writer) error {
+	type openElement struct {
+		tag    atom.Atom
+		secret bool
+	}
+
+	var (
+		z       = html.NewTokenizer(in)
+		stack   []openElement
+		secrets int
+	)
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return err
+			}
+			return nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			secret := false
+			// The titles of timestamps are ISO dates, which a contact
+			// named by number could otherwise match.
+			timestamp := false
+			for _, a := range tok.Attr {
+				if tok.DataAtom == atom.Abbr && a.Key == "class" && (a.Val == "published" || a.Val == "dt") {
+					timestamp = true
+				}
+			}
+			for i, a := range tok.Attr {
+				switch a.Key {
+				case "class":
+					secret = tok.DataAtom == atom.Span && a.Val == "full-text"
+				case "href":
+					if strings.HasPrefix(a.Val, "tel:") {
+						tok.Attr[i].Val = "tel:" + r.number(strings.TrimPrefix(a.Val, "tel:"))
+					} else {
+						tok.Attr[i].Val = r.replaceNames(a.Val)
+					}
+				case "title":
+					if !timestamp {
+						tok.Attr[i].Val = r.replaceNames(a.Val)
+					}
+				case "src":
+					tok.Attr[i].Val = r.replaceNames(a.Val)
+				}
+			}
+			if tok.DataAtom == atom.Q {
+				secret = true
+			}
+			if tt == html.StartTagToken && !voidElements[tok.DataAtom] {
+				stack = append(stack, openElement{tag: tok.DataAtom, secret: secret})
+				if secret {
+					secrets++
+				}
+			}
+			if _, err := io.WriteString(out, tok.String()); err != nil {
+				return err
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := atom.Lookup(name)
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].tag == tag {
+					for _, el := range stack[i:] {
+						if el.secret {
+							secrets--
+						}
+					}
+					stack = stack[:i]
+					break
+				}
+			}
+			if _, err := out.Write(z.Raw()); err != nil {
+				return err
+			}
+		case html.TextToken:
+			if n := len(stack); n > 0 && rawTextElements[stack[n-1].tag] {
+				// Style sheets and scripts aren't escaped and name no one.
+				if _, err := out.Write(z.Raw()); err != nil {
+					return err
+				}
+				continue
+			}
+			text := string(z.Text())
+			if secrets > 0 {
+				trimmed := strings.TrimSpace(text)
+				if trimmed != "" {
+					text = strings.Replace(text, trimmed, r.text(trimmed), 1)
+				}
+			} else {
+				text = r.replaceNames(text)
+			}
+			if _, err := io.WriteString(out, html.EscapeString(text)); err != nil {
+				return err
+			}
+		default:
+			if _, err := out.Write(z.Raw()); err != nil {
+				return err
+			}
+		}
+	}
+}
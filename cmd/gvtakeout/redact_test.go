@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var redactTestFiles = []string{"voicemail.html", "sms.html", "sms2.html", "mms.html", "missedcall.html"}
+
+// testdataSecrets are strings from the testdata that must not survive
+// redaction.
+var testdataSecrets = []string{"Sleve", "Mcdichael", "Smehrik", "Mike Truk", "Rortugal", "+8888", "+333", "Hahahaha"}
+
+func TestRedactTakeout(t *testing.T) {
+	in := t.TempDir()
+	out := filepath.Join(t.TempDir(), "redacted")
+
+	for _, name := range redactTestFiles {
+		input, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(in, name), input, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An attachment named after a contact that only appears in file names.
+	if err := os.WriteFile(filepath.Join(in, "Bob Loblaw - Text - 2022-07-01T01_06_39Z-1-1.jpg"), []byte("jpeg bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := newRedactor("lorem", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.redactTakeout(slog.Default(), in, out); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(redactTestFiles)+1 {
+		t.Fatalf("Expected %d files, got %d", len(redactTestFiles)+1, len(entries))
+	}
+	for _, e := range entries {
+		for _, secret := range testdataSecrets {
+			if strings.Contains(e.Name(), secret) || strings.Contains(e.Name(), "Loblaw") {
+				t.Errorf("File name %q still contains %q", e.Name(), secret)
+			}
+		}
+		if strings.HasSuffix(e.Name(), ".jpg") {
+			info, err := e.Info()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != 0 {
+				t.Errorf("Expected stripped image, got %d bytes", info.Size())
+			}
+		}
+	}
+
+	for _, name := range redactTestFiles {
+		redacted, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range testdataSecrets {
+			if bytes.Contains(redacted, []byte(secret)) {
+				t.Errorf("%s still contains %q", name, secret)
+			}
+		}
+
+		orig := parseTestdata(t, name)[0]
+		got, err := parseHTML(string(redacted))
+		if err != nil {
+			t.Fatalf("Failed to parse redacted %s: %v", name, err)
+		}
+
+		if got.Type != orig.Type {
+			t.Errorf("%s: Expected type %s, got %s", name, orig.Type, got.Type)
+		}
+		if !got.Timestamp.Equal(orig.Timestamp) {
+			t.Errorf("%s: Expected timestamp %s, got %s", name, orig.Timestamp, got.Timestamp)
+		}
+		if len(got.Participants) != len(orig.Participants) {
+			t.Errorf("%s: Expected %d participants, got %d", name, len(orig.Participants), len(got.Participants))
+		}
+		if utf8.RuneCountInString(got.Transcript) != utf8.RuneCountInString(orig.Transcript) {
+			t.Errorf("%s: Expected transcript of length %d, got %q", name, utf8.RuneCountInString(orig.Transcript), got.Transcript)
+		}
+		if len(got.Messages) != len(orig.Messages) {
+			t.Fatalf("%s: Expected %d messages, got %d", name, len(orig.Messages), len(got.Messages))
+		}
+		for i, m := range got.Messages {
+			want := orig.Messages[i]
+			if utf8.RuneCountInString(m.Content) != utf8.RuneCountInString(want.Content) {
+				t.Errorf("%s: Expected message %d of length %d, got %q", name, i, utf8.RuneCountInString(want.Content), m.Content)
+			}
+			if len(m.Images) != len(want.Images) {
+				t.Errorf("%s: Expected %d images in message %d, got %d", name, len(want.Images), i, len(m.Images))
+			}
+			if (m.Sender == "Me") != (want.Sender == "Me") {
+				t.Errorf("%s: Expected sender %q to map to Me consistently, got %q", name, want.Sender, m.Sender)
+			}
+		}
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	convs := parseTestdata(t, "sms.html", "mms.html")
+
+	var in bytes.Buffer
+	enc := json.NewEncoder(&in)
+	for _, conv := range convs {
+		if err := enc.Encode(conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := newRedactor("hash", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := r.redactJSON(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range testdataSecrets {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("Output still contains %q", secret)
+		}
+	}
+
+	var got []Conversation
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var conv Conversation
+		if err := dec.Decode(&conv); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, conv)
+	}
+	if len(got) != len(convs) {
+		t.Fatalf("Expected %d conversations, got %d", len(convs), len(got))
+	}
+
+	// The same contact gets the same pseudonym in both threads.
+	for _, p := range got[0].Participants {
+		if p.IsSelf {
+			continue
+		}
+		if got[1].Participants.find(p.Name, p.Number) < 0 {
+			t.Errorf("Expected %q in both conversations, got %v", p.Name, got[1].Participants)
+		}
+	}
+}
+
+func TestRedactTextHash(t *testing.T) {
+	r, err := newRedactor("hash", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := r.text("hello"), r.text("hello")
+	if a != b {
+		t.Errorf("Expected consistent hashes, got %q and %q", a, b)
+	}
+	if a == "hello" || a == r.text("goodbye") {
+		t.Errorf("Expected distinct hash, got %q", a)
+	}
+
+	// The same key hashes the same text alike in another run.
+	k1, err := newRedactor("hash", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := newRedactor("hash", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k1.text("hello") != k2.text("hello") {
+		t.Errorf("Expected the same hash with the same key, got %q and %q", k1.text("hello"), k2.text("hello"))
+	}
+	if k1.text("hello") == a {
+		t.Errorf("Expected a different hash with a different key, got %q", a)
+	}
+
+	if _, err := newRedactor("rot13", ""); err == nil {
+		t.Error("Expected error for unknown text mode")
+	}
+}
+
+func TestRedactReplaceNames(t *testing.T) {
+	r, err := newRedactor("lorem", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.name("Al")
+	r.name("Tony Smehrik")
+	r.number("+15550100")
+
+	for in, want := range map[string]string{
+		"Al - Text.html":                       "Contact 1 - Text.html",
+		"Alabama Al":                           "Alabama Contact 1",
+		"Tony Smehrik, Al and Tony Smehriks":   "Contact 2, Contact 1 and Tony Smehriks",
+		"tel:+15550100":                        "tel:+15550000001",
+		"+155501001":                           "+155501001",
+		"Group with Al, +15550100 and Sal-Al.": "Group with Contact 1, +15550000001 and Sal-Contact 1.",
+	} {
+		if got := r.replaceNames(in); got != want {
+			t.Errorf("replaceNames(%q): Expected %q, got %q", in, want, got)
+		}
+	}
+
+	// A contact named by number must not rewrite timestamps.
+	r.name("2022")
+	var out strings.Builder
+	in := `<abbr class="dt" title="2022-07-01T01:06:39.000-04:00">Jul 1, 2022</abbr><span title="2022">x</span>`
+	if err := r.redactHTML(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `title="2022-07-01T01:06:39.000-04:00"`) || strings.Contains(out.String(), `<span title="2022">`) {
+		t.Errorf("Expected the timestamp title kept and other titles redacted, got %s", out.String())
+	}
+}
+
+func TestRedactHTMLRawText(t *testing.T) {
+	r, err := newRedactor("lorem", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.name("Tony Smehrik")
+
+	in := `<style type="text/css">a > .fn::after { content: "&amp;" }</style>` +
+		`<script>if (a < b && c) {}</script><span class="fn">Tony Smehrik &amp; co &lt;3</span>`
+	var out strings.Builder
+	if err := r.redactHTML(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `<style type="text/css">a > .fn::after { content: "&amp;" }</style>` +
+		`<script>if (a < b && c) {}</script><span class="fn">Contact 1 &amp; co &lt;3</span>`
+	if out.String() != want {
+		t.Errorf("Expected style and script copied verbatim\nExpected: %s\nGot:      %s", want, out.String())
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+func runSearch(args []string) error {
+	fs, common := newFlagSet("search", "<text>")
+	account := fs.String("account", "", "Only search this account")
+	limit := fs.Int("limit", 50, "Maximum number of results, newest first (0 for no limit)")
+	context := fs.Int("context", 0, "Show this many messages before and after each match")
+	color := fs.String("color", "auto", "Color sender names: auto, always, or never")
+	asJSON := fs.Bool("json", false, "Print results as newline delimited JSON")
+	fs.Parse(args)
+
+	text := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(text) == "" {
+		fs.Usage()
+		return fmt.Errorf("missing search text")
+	}
+
+	loc, err := common.setup()
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := common.openDB()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	results, err := searchMessages(db, text, *account, *limit, loc)
+	if err != nil {
+		return err
+	}
+	if *context > 0 {
+		for i := range results {
+			if err := results[i].loadContext(db, *context, loc); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range results {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	p, err := newPalette(*color, os.Stdout)
+	if err != nil {
+		return err
+	}
+	printSearchResults(os.Stdout, results, *context > 0, p)
+	return nil
+}
+
+// searchResult is a message or voicemail transcript matching a search.
+type searchResult struct {
+	// ConversationID and MessageID are stable IDs, as show takes.
+	ConversationID string    `json:"conversation_id"`
+	MessageID      string    `json:"message_id,omitempty"`
+	Type           string    `json:"type"`
+	Timestamp      time.Time `json:"timestamp"`
+	Sender         string    `json:"sender"`
+	SenderNumber   string    `json:"sender_number"`
+	Text           string    `json:"text"`
+	// Transcript is set when Text is a voicemail transcript rather than a
+	// message.
+	Transcript bool `json:"transcript,omitempty"`
+	// Before and After are the neighbouring messages in the thread, filled
+	// in by loadContext.
+	Before []Message `json:"before,omitempty"`
+	After  []Message `json:"after,omitempty"`
+
+	// convID and msgID are the database ids, for loadContext.
+	convID, msgID int64
+}
+
+// searchMessages finds messages and transcripts containing text, case
+// insensitively for ASCII, newest first.
+func searchMessages(db *sql.DB, text, account string, limit int, loc *time.Location) ([]searchResult, error) {
+	pattern := "%" + escapeLike(text) + "%"
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := db.Query(`SELECT conversation.id, conversation.type, message.timestamp_ms, message.utc_offset,
+			COALESCE(contact.name, ''), COALESCE(contact.phone_number, ''), message.content, 0, message.id,
+			conversation.uid, message.uid
+		FROM message
+		JOIN conversation ON conversation.id = message.conversation_id
+		LEFT JOIN contact ON contact.id = message.sender_contact_id
+		WHERE message.content LIKE ? ESCAPE '\' AND (? = '' OR conversation.account = ?)
+		UNION ALL
+		SELECT conversation.id, conversation.type, conversation.timestamp_ms, conversation.utc_offset,
+			COALESCE((SELECT contact.name FROM participant JOIN contact ON contact.id = participant.contact_id
+				WHERE participant.conversation_id = conversation.id ORDER BY participant.id LIMIT 1), ''),
+			COALESCE((SELECT contact.phone_number FROM participant JOIN contact ON contact.id = participant.contact_id
+				WHERE participant.conversation_id = conversation.id ORDER BY participant.id LIMIT 1), ''),
+			conversation.transcript, 1, 0, conversation.uid, ''
+		FROM conversation
+		WHERE conversation.transcript LIKE ? ESCAPE '\' AND (? = '' OR conversation.account = ?)
+		ORDER BY 3 DESC, 1 DESC
+		LIMIT ?`, pattern, account, account, pattern, account, account, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	var results []searchResult
+	for rows.Next() {
+		var (
+			r          searchResult
+			ms, offset sql.NullInt64
+		)
+		if err := rows.Scan(&r.convID, &r.Type, &ms, &offset, &r.Sender, &r.SenderNumber, &r.Text, &r.Transcript, &r.msgID, &r.ConversationID, &r.MessageID); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		if ms.Valid {
+			r.Timestamp = localTime(ms.Int64, int(offset.Int64), loc)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+	return results, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// loadContext fills in up to n messages on either side of a message match,
+// in time order. Messages added from a later takeout can be older than
+// ones stored before them, so ids aren't in time order. Transcripts have no
+// neighbours.
+func (r *searchResult) loadContext(db *sql.DB, n int, loc *time.Location) error {
+	if r.Transcript {
+		return nil
+	}
+
+	var err error
+	r.Before, err = threadMessages(db, `m.conversation_id = ? AND (m.timestamp_ms, m.id) < (SELECT timestamp_ms, id FROM message WHERE id = ?)
+		ORDER BY m.timestamp_ms DESC, m.id DESC LIMIT ?`, loc, r.convID, r.msgID, n)
+	if err != nil {
+		return err
+	}
+	for i, j := 0, len(r.Before)-1; i < j; i, j = i+1, j-1 {
+		r.Before[i], r.Before[j] = r.Before[j], r.Before[i]
+	}
+
+	r.After, err = threadMessages(db, `m.conversation_id = ? AND (m.timestamp_ms, m.id) > (SELECT timestamp_ms, id FROM message WHERE id = ?)
+		ORDER BY m.timestamp_ms, m.id LIMIT ?`, loc, r.convID, r.msgID, n)
+	return err
+}
+
+// threadMessages returns the messages selected by where, which may also
+// order and limit them.
+func threadMessages(db *sql.DB, where string, loc *time.Location, args ...any) ([]Message, error) {
+	rows, err := db.Query(`SELECT m.timestamp_ms, m.utc_offset, COALESCE(c.name, ''), COALESCE(c.phone_number, ''), m.content
+		FROM message m LEFT JOIN contact c ON c.id = m.sender_contact_id
+		WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query context messages: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []Message
+	for rows.Next() {
+		var (
+			m          Message
+			ms, offset sql.NullInt64
+		)
+		if err := rows.Scan(&ms, &offset, &m.Sender, &m.SenderNumber, &m.Content); err != nil {
+			return nil, fmt.Errorf("failed to scan context message: %w", err)
+		}
+		if ms.Valid {
+			m.Timestamp = localTime(ms.Int64, int(offset.Int64), loc)
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating context messages: %w", err)
+	}
+	return msgs, nil
+}
+
+// printSearchResults prints one line per result. With context, matches are
+// marked with '>' and each group of lines is separated by "--" as grep does.
+func printSearchResults(out io.Writer, results []searchResult, context bool, p palette) {
+	for i, r := range results {
+		kind := r.Type
+		if r.Transcript {
+			kind += " transcript"
+		}
+		tag := fmt.Sprintf("[%s %s]", kind, r.ConversationID)
+
+		if !context {
+			fmt.Fprintf(out, "%s  %s  %s: %s\n", r.Timestamp.Format("2006-01-02 15:04"), tag, p.sender(r.Sender), oneLine(r.Text))
+			continue
+		}
+
+		if i > 0 {
+			fmt.Fprintln(out, p.dim("--"))
+		}
+		for _, m := range r.Before {
+			fmt.Fprintln(out, p.dim(fmt.Sprintf("  %s  %s  %s: %s", m.Timestamp.Format("2006-01-02 15:04"), tag, m.Sender, oneLine(m.Content))))
+		}
+		fmt.Fprintf(out, "> %s  %s  %s: %s\n", r.Timestamp.Format("2006-01-02 15:04"), tag, p.sender(r.Sender), oneLine(r.Text))
+		for _, m := range r.After {
+			fmt.Fprintln(out, p.dim(fmt.Sprintf("  %s  %s  %s: %s", m.Timestamp.Format("2006-01-02 15:04"), tag, m.Sender, oneLine(m.Content))))
+		}
+	}
+}
+
+// oneLine collapses the whitespace in s, including newlines, to single
+// spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,996 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// passwordEnv, if set, enables basic auth like -password-file.
+const passwordEnv = "GVTAKEOUT_VIEWER_PASSWORD"
+
+// db, templates, and displayLoc are set up by runServe for the handlers, and
+// db and displayLoc by runBrowse.
+var db *sql.DB
+var templates *template.Template
+var displayLoc *time.Location
+
+type storedConversation struct {
+	ID           int
+	Type         string
+	Timestamp    time.Time
+	Duration     string
+	Transcript   string
+	Participants []storedParticipant
+}
+
+// storedParticipant is a participant as the viewer shows them, with the
+// name, organization and photo from import-contacts if there are any.
+type storedParticipant struct {
+	ID           int
+	ContactID    int
+	ContactUID   string
+	Name         string
+	PhoneNumber  string
+	Organization string
+	HasPhoto     bool
+	IsSelf       bool
+	Source       string
+}
+
+type storedMessage struct {
+	ID              int
+	UID             string
+	Timestamp       time.Time
+	SenderContactID int
+	SenderName      string
+	SenderNumber    string
+	Content         string
+	ImageURL        *string
+	Reactions       []storedReaction
+}
+
+type storedReaction struct {
+	Kind       string
+	SenderName string
+}
+
+// Emoji returns the emoji shown for the reaction.
+func (r storedReaction) Emoji() string {
+	return reactionEmoji(r.Kind)
+}
+
+// serveFlags are the flags specific to the serve command.
+type serveFlags struct {
+	addr         string
+	passwordFile string
+	useToken     bool
+	templatesDir string
+}
+
+func runServe(args []string) error {
+	flags, common := newFlagSet("serve", "")
+	var sf serveFlags
+	flags.StringVar(&sf.addr, "addr", "127.0.0.1:8080", "HTTP server address")
+	flags.StringVar(&sf.passwordFile, "password-file", "", "Require HTTP basic auth with the password in this file (or set "+passwordEnv+")")
+	flags.BoolVar(&sf.useToken, "token", false, "Require a random login token, printed at startup as a login URL")
+	flags.StringVar(&sf.templatesDir, "templates", "", "Directory of templates (and a static/ subdirectory) overriding the built-in ones")
+	flags.Parse(args)
+
+	var err error
+	displayLoc, err = common.setup()
+	if err != nil {
+		return err
+	}
+
+	var static fs.FS
+	templates, static, err = loadAssets(sf.templatesDir)
+	if err != nil {
+		return fmt.Errorf("parse templates err: %w", err)
+	}
+
+	var closeDB func()
+	db, closeDB, err = common.openDB()
+	if err != nil {
+		return fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	defer closeDB()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", indexHandler)
+	mux.HandleFunc("GET /group/{thread}", groupHandler)
+	mux.HandleFunc("GET /calls", callsHandler)
+	mux.HandleFunc("GET /call/{id}/audio", callAudioHandler)
+	mux.HandleFunc("GET /contact/{id}/photo", contactPhotoHandler)
+	mux.HandleFunc("GET /greetings", greetingsHandler)
+	mux.HandleFunc("GET /greeting/{id}/audio", greetingAudioHandler)
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+
+	handler, err := authHandler(mux, sf)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting server on %s", sf.addr)
+	if err := http.ListenAndServe(sf.addr, securityHeaders(handler)); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	return nil
+}
+
+// authHandler wraps h with the login configured by -password-file,
+// $GVTAKEOUT_VIEWER_PASSWORD, or -token.
+func authHandler(h http.Handler, sf serveFlags) (http.Handler, error) {
+	password := os.Getenv(passwordEnv)
+	if sf.passwordFile != "" {
+		b, err := os.ReadFile(sf.passwordFile)
+		if err != nil {
+			return nil, fmt.Errorf("read password file: %w", err)
+		}
+		password = strings.TrimSpace(string(b))
+		if password == "" {
+			return nil, fmt.Errorf("password file %s is empty", sf.passwordFile)
+		}
+	}
+
+	switch {
+	case password != "" && sf.useToken:
+		return nil, fmt.Errorf("use either a password or -token, not both")
+	case password != "":
+		log.Printf("Basic auth enabled")
+		return requirePassword(h, password), nil
+	case sf.useToken:
+		token, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Login URL: http://%s/?token=%s", displayAddr(sf.addr), token)
+		return requireToken(h, token), nil
+	}
+
+	if !isLoopback(sf.addr) {
+		slog.Warn("serving without authentication; anyone who can reach it can read every message", "addr", sf.addr)
+	}
+	return h, nil
+}
+
+// displayAddr fills in a host for addresses like ":8080" so the login URL
+// can be opened as printed.
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+func indexHandler(w http.ResponseWriter, r *http.Request) {
+	account := r.URL.Query().Get("account")
+
+	accounts, err := getAccounts()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch accounts: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	groups, err := getGroups(account)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch groups: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Accounts []string
+		Account  string
+		Groups   []Group
+	}{
+		Accounts: accounts,
+		Account:  account,
+		Groups:   groups,
+	}
+
+	if err := templates.ExecuteTemplate(w, "index.html", data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render template: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func groupHandler(w http.ResponseWriter, r *http.Request) {
+	thread := r.PathValue("thread")
+	account := r.URL.Query().Get("account")
+
+	msgs, err := getMessagesForGroup(thread, account)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch messages: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	g := Group{
+		Key: thread,
+	}
+
+	seenParticipants := make(map[int]struct{})
+	for _, msg := range msgs {
+
+		if msg.Timestamp.After(g.Timestamp) {
+			g.Timestamp = msg.Timestamp
+		}
+
+		if _, seen := seenParticipants[msg.SenderContactID]; seen {
+			continue
+		}
+		p := storedParticipant{
+			ContactID:   msg.SenderContactID,
+			Name:        msg.SenderName,
+			PhoneNumber: msg.SenderNumber,
+		}
+		g.Participants = append(g.Participants, p)
+		seenParticipants[p.ContactID] = struct{}{}
+	}
+	if err := fillContactDetails(g.Participants); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch contacts: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Account  string
+		Group    Group
+		Messages []storedMessage
+	}{
+		Account:  account,
+		Group:    g,
+		Messages: msgs,
+	}
+
+	if err := templates.ExecuteTemplate(w, "group.html", data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render template: %s", err), http.StatusInternalServerError)
+	}
+}
+
+func getMessagesForGroup(thread, account string) ([]storedMessage, error) {
+	conversationIDs, err := getConversationIDsForGroup(thread, account)
+	if err != nil {
+		return nil, err
+	}
+
+	qs := make([]string, len(conversationIDs))
+	for i := range qs {
+		qs[i] = "?"
+	}
+	qsStr := strings.Join(qs, ",")
+
+	query := `
+		SELECT m.id, m.uid, m.timestamp_ms, m.utc_offset, m.sender_contact_id, c.name, c.phone_number, m.content, i.image_url
+		FROM message m
+		LEFT JOIN image i ON m.id = i.message_id
+		LEFT JOIN contact_display c ON m.sender_contact_id = c.id
+		WHERE m.conversation_id in (%s)
+		ORDER BY m.timestamp_ms DESC
+	`
+	query = fmt.Sprintf(query, qsStr)
+	convIDs := make([]any, len(conversationIDs))
+	for i, cid := range conversationIDs {
+		convIDs[i] = cid
+	}
+	rows, err := db.Query(query, convIDs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []storedMessage
+	msgIndex := make(map[int][]int)
+	for rows.Next() {
+		var (
+			m      storedMessage
+			ms     int64
+			offset int
+		)
+		err := rows.Scan(&m.ID, &m.UID, &ms, &offset, &m.SenderContactID, &m.SenderName, &m.SenderNumber, &m.Content, &m.ImageURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message row: %v", err)
+		}
+		m.Timestamp = localTime(ms, offset, displayLoc)
+		msgIndex[m.ID] = append(msgIndex[m.ID], len(messages))
+		messages = append(messages, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating message rows: %v", err)
+	}
+	rows.Close()
+
+	query = `
+		SELECT r.message_id, r.kind, COALESCE(c.name, '')
+		FROM reaction r
+		JOIN message m ON m.id = r.message_id
+		LEFT JOIN contact_display c ON r.sender_contact_id = c.id
+		WHERE m.conversation_id in (%s)
+		ORDER BY r.id
+	`
+	rows, err = db.Query(fmt.Sprintf(query, qsStr), convIDs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reactions: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			msgID int
+			r     storedReaction
+		)
+		if err := rows.Scan(&msgID, &r.Kind, &r.SenderName); err != nil {
+			return nil, fmt.Errorf("failed to scan reaction row: %v", err)
+		}
+		for _, i := range msgIndex[msgID] {
+			messages[i].Reactions = append(messages[i].Reactions, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reaction rows: %v", err)
+	}
+
+	return messages, nil
+}
+
+// fillContactDetails sets the stable ID, organization and photo of
+// participants known only from the messages they sent.
+func fillContactDetails(participants []storedParticipant) error {
+	for i := range participants {
+		p := &participants[i]
+		err := db.QueryRow("SELECT uid, organization, has_photo FROM contact_display WHERE id = ?", p.ContactID).
+			Scan(&p.ContactUID, &p.Organization, &p.HasPhoto)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to query contact: %v", err)
+		}
+	}
+	return nil
+}
+
+// getConversationIDsForGroup returns the conversations of a thread that
+// belong to account, or to any account if it is empty.
+func getConversationIDsForGroup(thread, account string) ([]int, error) {
+	rows, err := db.Query("SELECT id FROM conversation WHERE thread_uid = ? AND (? = '' OR account = ?) ORDER BY id", thread, account, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversations: %s", err)
+	}
+	defer rows.Close()
+
+	var conversationIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation row: %s", err)
+		}
+		conversationIDs = append(conversationIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating conversation rows: %v", err)
+	}
+
+	return conversationIDs, nil
+}
+
+// getAccounts returns the named accounts that have conversations in the
+// database.
+func getAccounts() ([]string, error) {
+	rows, err := db.Query("SELECT DISTINCT account FROM conversation WHERE account != '' ORDER BY account")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %v", err)
+	}
+	defer rows.Close()
+
+	var accounts []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, fmt.Errorf("failed to scan account row: %v", err)
+		}
+		accounts = append(accounts, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating account rows: %v", err)
+	}
+
+	return accounts, nil
+}
+
+// Group is a thread in the index: every conversation with the same set of
+// participants.
+type Group struct {
+	// Key is the thread's stable ID.
+	Key  string
+	Type string
+	// Timestamp is the last activity in the group, whether a message, a
+	// call, or a voicemail.
+	Timestamp time.Time
+	// LastConversationUID is the stable ID of the group's most recently
+	// active conversation.
+	LastConversationUID string
+	Participants        []storedParticipant
+	MessageCount        int
+	// LastMessage is the group's most recent text message, or nil if it has
+	// only calls and voicemails.
+	LastMessage *storedMessage
+	// Badges count the group's calls and voicemails by type.
+	Badges []groupBadge
+}
+
+type groupBadge struct {
+	Type  string
+	Count int
+}
+
+// badgeNames are the singular and plural names of the call types.
+var badgeNames = map[string][2]string{
+	"voicemail":     {"voicemail", "voicemails"},
+	"missed_call":   {"missed call", "missed calls"},
+	"received_call": {"received call", "received calls"},
+	"placed_call":   {"placed call", "placed calls"},
+	"recorded_call": {"recorded call", "recorded calls"},
+	"unknown_call":  {"other call", "other calls"},
+}
+
+// Label describes the badge, e.g. "2 missed calls".
+func (b groupBadge) Label() string {
+	names, ok := badgeNames[b.Type]
+	if !ok {
+		name := strings.ReplaceAll(b.Type, "_", " ")
+		names = [2]string{name, name + "s"}
+	}
+	if b.Count == 1 {
+		return "1 " + names[0]
+	}
+	return strconv.Itoa(b.Count) + " " + names[1]
+}
+
+// groupConversations keys each conversation of an account by its thread,
+// lists its participant contact ids and finds its last activity. It is the
+// common part of the getGroups queries and takes the account twice.
+const groupConversations = `WITH conv AS (
+		SELECT conversation.id, conversation.uid, conversation.type, conversation.utc_offset, conversation.thread_uid AS group_key,
+			(SELECT group_concat(DISTINCT participant.contact_id ORDER BY participant.contact_id)
+				FROM participant WHERE participant.conversation_id = conversation.id) AS contact_ids,
+			MAX(COALESCE(conversation.timestamp_ms, 0),
+				COALESCE((SELECT MAX(message.timestamp_ms) FROM message WHERE message.conversation_id = conversation.id), 0)) AS last_ms
+		FROM conversation
+		WHERE (? = '' OR conversation.account = ?)
+	)`
+
+// getGroups returns the conversation groups for account, or for all
+// accounts if account is empty, most recently active first.
+func getGroups(account string) ([]Group, error) {
+	query := groupConversations + `,
+	latest AS (
+		SELECT conv.*, ROW_NUMBER() OVER (PARTITION BY group_key ORDER BY last_ms DESC, id DESC) AS rn
+		FROM conv WHERE group_key IS NOT NULL
+	),
+	message_count AS (
+		SELECT conv.group_key, COUNT(*) AS n
+		FROM message JOIN conv ON conv.id = message.conversation_id
+		GROUP BY conv.group_key
+	),
+	last_message AS (
+		SELECT conv.group_key, message.id, message.timestamp_ms, message.utc_offset, message.sender_contact_id,
+			contact.name, contact.phone_number, message.content,
+			ROW_NUMBER() OVER (PARTITION BY conv.group_key ORDER BY message.timestamp_ms DESC, message.id DESC) AS rn
+		FROM message
+		JOIN conv ON conv.id = message.conversation_id
+		LEFT JOIN contact_display contact ON contact.id = message.sender_contact_id
+	)
+	SELECT latest.group_key, latest.contact_ids, latest.uid, latest.type, latest.last_ms, latest.utc_offset, COALESCE(message_count.n, 0),
+		last_message.id, last_message.timestamp_ms, last_message.utc_offset, last_message.sender_contact_id,
+		last_message.name, last_message.phone_number, last_message.content
+	FROM latest
+	LEFT JOIN message_count ON message_count.group_key = latest.group_key
+	LEFT JOIN last_message ON last_message.group_key = latest.group_key AND last_message.rn = 1
+	WHERE latest.rn = 1
+	ORDER BY latest.last_ms DESC, latest.id DESC`
+	rows, err := db.Query(query, account, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups: %v", err)
+	}
+	defer rows.Close()
+
+	var (
+		groups       []Group
+		groupsByKey  = make(map[string]int)
+		participants = make(map[int]storedParticipant)
+	)
+	for rows.Next() {
+		var (
+			g            Group
+			ms, offset   int64
+			msgID, msgMs sql.NullInt64
+			msgOffset    sql.NullInt64
+			senderID     sql.NullInt64
+			name, number sql.NullString
+			content      sql.NullString
+			contactIDs   sql.NullString
+		)
+		err := rows.Scan(&g.Key, &contactIDs, &g.LastConversationUID, &g.Type, &ms, &offset, &g.MessageCount,
+			&msgID, &msgMs, &msgOffset, &senderID, &name, &number, &content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan group row: %v", err)
+		}
+		g.Timestamp = localTime(ms, int(offset), displayLoc)
+		if msgID.Valid {
+			g.LastMessage = &storedMessage{
+				ID:              int(msgID.Int64),
+				Timestamp:       localTime(msgMs.Int64, int(msgOffset.Int64), displayLoc),
+				SenderContactID: int(senderID.Int64),
+				SenderName:      name.String,
+				SenderNumber:    number.String,
+				Content:         content.String,
+			}
+		}
+		for _, id := range strings.Split(contactIDs.String, ",") {
+			if id == "" {
+				continue
+			}
+			cid, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, fmt.Errorf("bad contact ids %q: %v", contactIDs.String, err)
+			}
+			participants[cid] = storedParticipant{}
+			g.Participants = append(g.Participants, storedParticipant{ContactID: cid})
+		}
+		groupsByKey[g.Key] = len(groups)
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating group rows: %v", err)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT id, uid, name, phone_number, organization, has_photo,
+		EXISTS (SELECT 1 FROM participant WHERE participant.contact_id = contact.id AND participant.is_self)
+		FROM contact_display contact`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contacts: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p storedParticipant
+		if err := rows.Scan(&p.ContactID, &p.ContactUID, &p.Name, &p.PhoneNumber, &p.Organization, &p.HasPhoto, &p.IsSelf); err != nil {
+			return nil, fmt.Errorf("failed to scan contact row: %v", err)
+		}
+		if _, ok := participants[p.ContactID]; ok {
+			participants[p.ContactID] = p
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating contact rows: %v", err)
+	}
+	rows.Close()
+	for i := range groups {
+		for j, p := range groups[i].Participants {
+			groups[i].Participants[j] = participants[p.ContactID]
+		}
+	}
+
+	rows, err = db.Query(groupConversations+`
+		SELECT group_key, type, COUNT(*) FROM conv
+		WHERE group_key IS NOT NULL AND type != 'chat'
+		GROUP BY group_key, type
+		ORDER BY group_key, COUNT(*) DESC, type`, account, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query call counts: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			key string
+			b   groupBadge
+		)
+		if err := rows.Scan(&key, &b.Type, &b.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan call count row: %v", err)
+		}
+		if i, ok := groupsByKey[key]; ok {
+			groups[i].Badges = append(groups[i].Badges, b)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating call count rows: %v", err)
+	}
+
+	return groups, nil
+}
+
+func getConversations(limit, offset int, searchTerm string) ([]storedConversation, error) {
+	query := `
+		SELECT DISTINCT c.id, c.type, c.timestamp_ms, c.utc_offset, c.duration
+		FROM conversation c
+		LEFT JOIN message m ON c.id = m.conversation_id
+		LEFT JOIN contact_display ct ON m.sender_contact_id = ct.id
+		WHERE c.transcript LIKE ? OR m.content LIKE ? OR ct.name LIKE ?
+		ORDER BY c.timestamp_ms DESC
+		LIMIT ? OFFSET ?
+	`
+	searchPattern := "%" + searchTerm + "%"
+	rows, err := db.Query(query, searchPattern, searchPattern, searchPattern, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversation: %v", err)
+	}
+	defer rows.Close()
+
+	var conversations []storedConversation
+	for rows.Next() {
+		var c storedConversation
+		var ms int64
+		var offset int
+		err := rows.Scan(&c.ID, &c.Type, &ms, &offset, &c.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation row: %v", err)
+		}
+		c.Timestamp = localTime(ms, offset, displayLoc)
+
+		// Fetch participants for this conversation
+		participants, err := getParticipants(c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get participants for conversation %d: %v", c.ID, err)
+		}
+		c.Participants = participants
+
+		// Get transcript
+		transcript, err := getTranscript(c.ID, c.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transcript for conversation %d: %v", c.ID, err)
+		}
+		c.Transcript = transcript
+
+		conversations = append(conversations, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating conversation rows: %v", err)
+	}
+
+	return conversations, nil
+}
+
+func getParticipants(conversationID int) ([]storedParticipant, error) {
+	query := `
+		SELECT p.id, p.contact_id, c.uid, c.name, c.phone_number, c.organization, c.has_photo, p.is_self, p.source
+		FROM participant p
+		JOIN contact_display c ON p.contact_id = c.id
+		WHERE p.conversation_id = ?
+		ORDER BY p.id
+	`
+	rows, err := db.Query(query, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query participant: %v", err)
+	}
+	defer rows.Close()
+
+	var participants []storedParticipant
+	for rows.Next() {
+		var p storedParticipant
+		err := rows.Scan(&p.ID, &p.ContactID, &p.ContactUID, &p.Name, &p.PhoneNumber, &p.Organization, &p.HasPhoto, &p.IsSelf, &p.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan participant row: %v", err)
+		}
+		participants = append(participants, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating participant rows: %v", err)
+	}
+
+	return participants, nil
+}
+
+func getTotalConversationCount(searchTerm string) (int, error) {
+	query := "SELECT COUNT(*) FROM conversation WHERE transcript LIKE ?"
+	searchPattern := "%" + searchTerm + "%"
+	var count int
+	err := db.QueryRow(query, searchPattern).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get total conversation count: %v", err)
+	}
+	return count, nil
+}
+
+func getConversationByID(id int) (storedConversation, error) {
+	query := `
+		SELECT id, type, timestamp_ms, utc_offset, duration, transcript
+		FROM conversation
+		WHERE id = ?
+	`
+	var c storedConversation
+	var ms int64
+	var offset int
+	err := db.QueryRow(query, id).Scan(&c.ID, &c.Type, &ms, &offset, &c.Duration, &c.Transcript)
+	if err != nil {
+		return storedConversation{}, fmt.Errorf("failed to fetch conversation: %v", err)
+	}
+	c.Timestamp = localTime(ms, offset, displayLoc)
+	return c, nil
+}
+
+func getTranscript(conversationID int, conversationType string) (string, error) {
+	if conversationType == "voicemail" {
+		// For voicemail, we already have the transcript in the conversation table
+		var transcript string
+		err := db.QueryRow("SELECT transcript FROM conversation WHERE id = ?", conversationID).Scan(&transcript)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch voicemail transcript: %v", err)
+		}
+		return transcript, nil
+	}
+
+	// For chat messages, build the transcript from the messages table
+	query := `
+		SELECT c.name, m.content
+		FROM message m
+		JOIN contact_display c ON m.sender_contact_id = c.id
+		WHERE m.conversation_id = ?
+		ORDER BY m.timestamp_ms ASC
+		LIMIT 5
+	`
+	rows, err := db.Query(query, conversationID)
+	if err != nil {
+		return "", fmt.Errorf("failed to query message for transcript: %v", err)
+	}
+	defer rows.Close()
+
+	var transcript strings.Builder
+	for rows.Next() {
+		var senderName, content string
+		err := rows.Scan(&senderName, &content)
+		if err != nil {
+			return "", fmt.Errorf("failed to scan message row: %v", err)
+		}
+		transcript.WriteString(fmt.Sprintf("%s: %s\n", senderName, content))
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error iterating message rows: %v", err)
+	}
+
+	return transcript.String(), nil
+}
+
+type storedGreeting struct {
+	UID        string
+	Name       string
+	RecordedAt time.Time
+	FileName   string
+}
+
+func greetingsHandler(w http.ResponseWriter, r *http.Request) {
+	account := r.URL.Query().Get("account")
+	greetings, err := getGreetings(account)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch greetings: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Account   string
+		Greetings []storedGreeting
+	}{
+		Account:   account,
+		Greetings: greetings,
+	}
+
+	if err := templates.ExecuteTemplate(w, "greetings.html", data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render template: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func greetingAudioHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		fileName string
+		content  []byte
+	)
+	err := db.QueryRow("SELECT file_name, content FROM greeting WHERE uid = ?", r.PathValue("id")).Scan(&fileName, &content)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch greeting: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	serveAudio(w, r, fileName, content)
+}
+
+// contactPhotoHandler serves a contact's photo from import-contacts.
+func contactPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		contentType string
+		content     []byte
+	)
+	err := db.QueryRow(`SELECT contact_detail.photo_type, contact_detail.photo_data
+		FROM contact_detail JOIN contact ON contact.id = contact_detail.contact_id
+		WHERE contact.uid = ? AND contact_detail.photo_data IS NOT NULL`, r.PathValue("id")).Scan(&contentType, &content)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch contact photo: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+// serveAudio writes a stored audio file with a content type guessed from its
+// name.
+func serveAudio(w http.ResponseWriter, r *http.Request, fileName string, content []byte) {
+	contentType := mime.TypeByExtension(filepath.Ext(fileName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, filepath.Base(fileName), time.Time{}, bytes.NewReader(content))
+}
+
+// getGreetings returns the greetings of account, or of all accounts if
+// account is empty, newest first.
+func getGreetings(account string) ([]storedGreeting, error) {
+	rows, err := db.Query(`SELECT uid, name, timestamp_ms, utc_offset, file_name FROM greeting
+		WHERE (? = '' OR account = ?)
+		ORDER BY timestamp_ms DESC`, account, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query greetings: %v", err)
+	}
+	defer rows.Close()
+
+	var greetings []storedGreeting
+	for rows.Next() {
+		var (
+			g      storedGreeting
+			ms     int64
+			offset int
+		)
+		if err := rows.Scan(&g.UID, &g.Name, &ms, &offset, &g.FileName); err != nil {
+			return nil, fmt.Errorf("failed to scan greeting row: %v", err)
+		}
+		g.RecordedAt = localTime(ms, offset, displayLoc)
+		greetings = append(greetings, g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating greeting rows: %v", err)
+	}
+
+	return greetings, nil
+}
+
+// storedCall is a row of the call log. A missed call the caller left a
+// voicemail on is one row, with the voicemail attached.
+type storedCall struct {
+	ID         int
+	UID        string
+	Type       string
+	Timestamp  time.Time
+	Duration   string
+	Transcript string
+	Name       string
+	Number     string
+	HasAudio   bool
+	Voicemail  *storedCall
+}
+
+// Label returns the type of call for display.
+func (c storedCall) Label() string {
+	if names, ok := badgeNames[c.Type]; ok {
+		return names[0]
+	}
+	return strings.ReplaceAll(c.Type, "_", " ")
+}
+
+func callsHandler(w http.ResponseWriter, r *http.Request) {
+	account := r.URL.Query().Get("account")
+	calls, err := getCalls(account)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch calls: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Account string
+		Calls   []storedCall
+	}{
+		Account: account,
+		Calls:   calls,
+	}
+
+	if err := templates.ExecuteTemplate(w, "calls.html", data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render template: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func callAudioHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		fileName string
+		content  []byte
+	)
+	err := db.QueryRow(`SELECT call_audio.file_name, call_audio.content
+		FROM call_audio JOIN conversation ON conversation.id = call_audio.conversation_id
+		WHERE conversation.uid = ? AND call_audio.content IS NOT NULL
+		ORDER BY call_audio.id LIMIT 1`, r.PathValue("id")).Scan(&fileName, &content)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch call audio: %s", err), http.StatusInternalServerError)
+		return
+	}
+	serveAudio(w, r, fileName, content)
+}
+
+// getCalls returns every call, newest first. Voicemails linked to a missed
+// call by a call event are returned with that call rather than on their own.
+func getCalls(account string) ([]storedCall, error) {
+	rows, err := db.Query(`
+		SELECT c.id, c.uid, c.type, c.timestamp_ms, c.utc_offset, c.duration, c.transcript,
+			COALESCE((SELECT contact.name FROM participant JOIN contact_display contact ON contact.id = participant.contact_id
+				WHERE participant.conversation_id = c.id AND NOT participant.is_self ORDER BY participant.id LIMIT 1), ''),
+			COALESCE((SELECT contact.phone_number FROM participant JOIN contact_display contact ON contact.id = participant.contact_id
+				WHERE participant.conversation_id = c.id AND NOT participant.is_self ORDER BY participant.id LIMIT 1), ''),
+			EXISTS (SELECT 1 FROM call_audio WHERE call_audio.conversation_id = c.id AND call_audio.content IS NOT NULL),
+			v.id, v.uid, v.timestamp_ms, v.utc_offset, v.duration, v.transcript,
+			EXISTS (SELECT 1 FROM call_audio WHERE call_audio.conversation_id = v.id AND call_audio.content IS NOT NULL)
+		FROM conversation c
+		LEFT JOIN call_event ce ON ce.missed_call_id = c.id
+		LEFT JOIN conversation v ON v.id = ce.voicemail_id
+		WHERE c.type != 'chat'
+			AND c.id NOT IN (SELECT voicemail_id FROM call_event)
+			AND (? = '' OR c.account = ?)
+		ORDER BY c.timestamp_ms DESC, c.id DESC
+	`, account, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query calls: %v", err)
+	}
+	defer rows.Close()
+
+	var calls []storedCall
+	for rows.Next() {
+		var (
+			c                  storedCall
+			ms, offset         int64
+			vID, vMs, vOffset  sql.NullInt64
+			vUID               sql.NullString
+			vDuration, vScript sql.NullString
+			vHasAudio          bool
+		)
+		err := rows.Scan(&c.ID, &c.UID, &c.Type, &ms, &offset, &c.Duration, &c.Transcript, &c.Name, &c.Number, &c.HasAudio,
+			&vID, &vUID, &vMs, &vOffset, &vDuration, &vScript, &vHasAudio)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan call row: %v", err)
+		}
+		c.Timestamp = localTime(ms, int(offset), displayLoc)
+		if vID.Valid {
+			c.Voicemail = &storedCall{
+				ID:         int(vID.Int64),
+				UID:        vUID.String,
+				Type:       "voicemail",
+				Timestamp:  localTime(vMs.Int64, int(vOffset.Int64), displayLoc),
+				Duration:   vDuration.String,
+				Transcript: vScript.String,
+				Name:       c.Name,
+				Number:     c.Number,
+				HasAudio:   vHasAudio,
+			}
+		}
+		calls = append(calls, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating call rows: %v", err)
+	}
+	return calls, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetGroups(t *testing.T) {
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	dwigt := participantList{{Name: "Dwigt Rortugal", Number: "+66666", Source: participantFromCall}}
+	convs := append(parseTestdata(t, "sms.html", "mms.html"),
+		Conversation{Type: "missed_call", Participants: dwigt, Timestamp: ts, SourceFile: "a.html"},
+		Conversation{Type: "voicemail", Participants: dwigt, Timestamp: ts.Add(time.Hour), Duration: "00:00:05", Transcript: "hi", SourceFile: "b.html"},
+		Conversation{Type: "missed_call", Participants: dwigt, Timestamp: ts.Add(-time.Hour), SourceFile: "c.html"},
+	)
+	db = importConversations(t, convs)
+	displayLoc = time.UTC
+	defer func() { db, displayLoc = nil, nil }()
+
+	groups, err := getGroups("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %d: %+v", len(groups), groups)
+	}
+
+	// The calls are the most recent activity and the first row must not
+	// lose its conversation.
+	calls := groups[0]
+	if calls.Type != "voicemail" || !calls.Timestamp.Equal(ts.Add(time.Hour)) || calls.LastConversationUID == "" {
+		t.Errorf("Expected the voicemail as the latest activity, got %+v", calls)
+	}
+	if calls.MessageCount != 0 || calls.LastMessage != nil {
+		t.Errorf("Expected no messages for the calls group, got %+v", calls)
+	}
+	if len(calls.Badges) != 2 || calls.Badges[0].Label() != "2 missed calls" || calls.Badges[1].Label() != "1 voicemail" {
+		t.Errorf("Expected missed call and voicemail badges, got %+v", calls.Badges)
+	}
+	if len(calls.Participants) != 1 || calls.Participants[0].Name != "Dwigt Rortugal" {
+		t.Errorf("Expected Dwigt Rortugal as the participant, got %+v", calls.Participants)
+	}
+
+	mms := groups[1]
+	want := parseTestdata(t, "mms.html")[0]
+	last := want.Messages[len(want.Messages)-1]
+	if mms.MessageCount != len(want.Messages) || mms.LastMessage == nil || mms.LastMessage.Content != last.Content {
+		t.Errorf("Expected %d messages ending with %q, got %d, %+v", len(want.Messages), last.Content, mms.MessageCount, mms.LastMessage)
+	}
+	if !mms.Timestamp.Equal(last.Timestamp) {
+		t.Errorf("Expected last activity %v, got %v", last.Timestamp, mms.Timestamp)
+	}
+	if len(mms.Participants) != len(want.Participants) {
+		t.Errorf("Expected %d participants, got %+v", len(want.Participants), mms.Participants)
+	}
+
+	groups, err = getGroups("someone else")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("Expected no groups for another account, got %+v", groups)
+	}
+}
+
+func TestGetCalls(t *testing.T) {
+	db = importTestdata(t, "missedcall.html", "missedcall-voicemail.html", "voicemail-notranscript.html", "sms.html")
+	displayLoc = time.UTC
+	defer func() { db, displayLoc = nil, nil }()
+
+	calls, err := getCalls("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("Expected the voicemail to be folded into its missed call, got %+v", calls)
+	}
+	if calls[0].Type != "missed_call" || calls[0].Voicemail == nil || calls[0].Voicemail.Duration != "00:00:03" {
+		t.Errorf("Expected the newest missed call with its voicemail, got %+v", calls[0])
+	}
+	if calls[1].Name != "Dwigt Rortugal" || calls[1].Voicemail != nil {
+		t.Errorf("Expected the unlinked missed call, got %+v", calls[1])
+	}
+}
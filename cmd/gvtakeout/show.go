@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+func runShow(args []string) error {
+	fs, common := newFlagSet("show", "<conversation id>")
+	color := fs.String("color", "auto", "Color sender names: auto, always, or never")
+	asJSON := fs.Bool("json", false, "Print the conversation as JSON in the format of parse and export")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one conversation id")
+	}
+	uid := fs.Arg(0)
+
+	loc, err := common.setup()
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := common.openDB()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	id, err := conversationIDForUID(db, uid)
+	if err != nil {
+		return err
+	}
+	conv, err := loadConversation(db, id)
+	if err != nil {
+		return err
+	}
+	if loc != nil {
+		conv = conv.In(loc)
+	}
+
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(conv)
+	}
+
+	p, err := newPalette(*color, os.Stdout)
+	if err != nil {
+		return err
+	}
+	printConversation(os.Stdout, conv, p)
+	return nil
+}
+
+// printConversation prints a conversation as a readable transcript: a
+// header describing the call or thread, then one line per message.
+func printConversation(out io.Writer, conv Conversation, p palette) {
+	fmt.Fprintf(out, "%s %s  %s", conv.Type, conv.ID, conv.Timestamp.Format("2006-01-02 15:04 -0700"))
+	if conv.Duration != "" {
+		fmt.Fprintf(out, "  (%s)", conv.Duration)
+	}
+	fmt.Fprintln(out)
+
+	for _, part := range conv.Participants {
+		fmt.Fprintf(out, "  %s %s\n", p.sender(part.Name), p.dim(part.Number))
+	}
+	if conv.Account != "" {
+		fmt.Fprintf(out, "  %s\n", p.dim("account "+conv.Account))
+	}
+
+	if ev := conv.CallEvent; ev != nil {
+		if conv.Type == "voicemail" {
+			fmt.Fprintf(out, "  %s\n", p.dim("[left after the missed call at "+ev.MissedCall.Timestamp.Format("2006-01-02 15:04:05")+"]"))
+		} else {
+			fmt.Fprintf(out, "  %s\n", p.dim("[voicemail left at "+ev.Voicemail.Timestamp.Format("2006-01-02 15:04:05")+"]"))
+		}
+	}
+	if conv.Audio != "" {
+		fmt.Fprintf(out, "  %s\n", p.dim("[audio: "+conv.Audio+"]"))
+	}
+	if conv.Transcript != "" {
+		fmt.Fprintf(out, "\n%s\n", conv.Transcript)
+	}
+
+	if len(conv.Messages) > 0 {
+		fmt.Fprintln(out)
+	}
+	for _, m := range conv.Messages {
+		lines := strings.Split(m.Content, "\n")
+		fmt.Fprintf(out, "%s  %s: %s\n", m.Timestamp.Format("2006-01-02 15:04"), p.sender(m.Sender), lines[0])
+		for _, line := range lines[1:] {
+			fmt.Fprintf(out, "%18s%s\n", "", line)
+		}
+		for _, img := range m.Images {
+			fmt.Fprintf(out, "%18s%s\n", "", p.dim("[image: "+img+"]"))
+		}
+		if len(m.Reactions) > 0 {
+			fmt.Fprintf(out, "%18s%s\n", "", p.dim(describeReactions(m.Reactions)))
+		}
+	}
+}
+
+// palette colors terminal output. The zero palette leaves text unchanged.
+type palette struct {
+	enabled bool
+}
+
+// senderColors are the ANSI foreground colors given to sender names,
+// skipping black and white so names stay readable on either background.
+var senderColors = []string{"31", "32", "33", "34", "35", "36"}
+
+// newPalette interprets a -color flag value. auto colors only when f is a
+// terminal and NO_COLOR isn't set.
+func newPalette(mode string, f *os.File) (palette, error) {
+	switch mode {
+	case "always":
+		return palette{enabled: true}, nil
+	case "never":
+		return palette{}, nil
+	case "auto":
+		return palette{enabled: os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(f.Fd()))}, nil
+	}
+	return palette{}, fmt.Errorf("invalid -color %q: must be auto, always, or never", mode)
+}
+
+// sender colors a sender name. Each name always gets the same color and the
+// account owner is shown in bold.
+func (p palette) sender(name string) string {
+	if !p.enabled {
+		return name
+	}
+	if name == selfName {
+		return "\x1b[1m" + name + "\x1b[0m"
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return "\x1b[" + senderColors[h.Sum32()%uint32(len(senderColors))] + "m" + name + "\x1b[0m"
+}
+
+func (p palette) dim(s string) string {
+	if !p.enabled {
+		return s
+	}
+	return "\x1b[2m" + s + "\x1b[0m"
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchContext(t *testing.T) {
+	db := importTestdata(t, "mms.html")
+
+	results, err := searchMessages(db, "Hahaha I love", "", 0, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %+v", results)
+	}
+	r := results[0]
+	if err := r.loadContext(db, 2, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Before) != 2 || r.Before[1].Content != "Maybe this is your sign to get a hornet-skyscraper Peter" {
+		t.Errorf("Expected the two preceding messages, oldest first, got %+v", r.Before)
+	}
+	if len(r.After) != 0 {
+		t.Errorf("Expected no following messages, got %+v", r.After)
+	}
+
+	var buf bytes.Buffer
+	printSearchResults(&buf, []searchResult{r}, true, palette{})
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[2], "> ") || !strings.HasPrefix(lines[0], "  ") {
+		t.Errorf("Expected two context lines then the marked match, got:\n%s", buf.String())
+	}
+
+	// Results name conversations and messages by their stable IDs.
+	mms := parseTestdata(t, "mms.html")[0]
+	mms.Account = "+2222"
+	mms.assignIDs()
+	if !strings.Contains(lines[2], "[chat "+mms.ID+"]") {
+		t.Errorf("Expected the conversation's stable ID %s in %q", mms.ID, lines[2])
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	var msgID string
+	for _, m := range mms.Messages {
+		if strings.Contains(m.Content, "Hahaha I love") {
+			msgID = m.ID
+		}
+	}
+	if decoded["conversation_id"] != mms.ID || decoded["message_id"] != msgID {
+		t.Errorf("Expected conversation %s and message %s in the JSON result, got %s", mms.ID, msgID, b)
+	}
+}
+
+func TestShowConversation(t *testing.T) {
+	db := importTestdata(t, "voicemail.html", "mms.html")
+
+	results, err := searchMessages(db, "Hahaha I love", "", 0, time.UTC)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Expected 1 search result, got %+v, %v", results, err)
+	}
+	id, err := conversationIDForUID(db, results[0].ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv, err := loadConversation(db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parseTestdata(t, "mms.html")[0]
+	if conv.Type != want.Type || len(conv.Messages) != len(want.Messages) || conv.SourceFile != "mms.html" {
+		t.Errorf("Expected the mms.html conversation, got %+v", conv)
+	}
+
+	var buf bytes.Buffer
+	printConversation(&buf, conv, palette{})
+	out := buf.String()
+	for _, s := range []string{"chat " + results[0].ConversationID, "Tony Smehrik +333", "Tony Smehrik: Hahaha I love all of these", "[image: "} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in output:\n%s", s, out)
+		}
+	}
+
+	if _, err := conversationIDForUID(db, "1"); err == nil {
+		t.Error("Expected an error for a missing conversation")
+	}
+}
+
+func TestPalette(t *testing.T) {
+	if _, err := newPalette("sometimes", nil); err == nil {
+		t.Error("Expected an error for an invalid -color")
+	}
+
+	p := palette{enabled: true}
+	if p.sender("Tony Smehrik") != p.sender("Tony Smehrik") || !strings.Contains(p.sender("Tony Smehrik"), "\x1b[") {
+		t.Errorf("Expected a stable colored name, got %q", p.sender("Tony Smehrik"))
+	}
+	if got := (palette{}).sender("Tony Smehrik"); got != "Tony Smehrik" {
+		t.Errorf("Expected no color when disabled, got %q", got)
+	}
+}
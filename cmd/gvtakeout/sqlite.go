@@ -0,0 +1,830 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	RegisterOutputWriter("sqlite", func(opts OutputOptions) (OutputWriter, error) {
+		dbName := opts.DBPath
+		if dbName == "" {
+			dbName = "conversations.db"
+		}
+		return &sqliteWriter{dbName: strings.TrimSuffix(dbName, ".age"), enc: opts.Encryption}, nil
+	})
+}
+
+// sqliteWriter inserts each conversation into a SQLite database in its own
+// transaction.
+//
+// With encryption the database is built in a private temporary directory,
+// starting from the existing dbName.age if there is one, and encrypted to
+// dbName.age on Close.
+type sqliteWriter struct {
+	dbName string
+	db     *sql.DB
+
+	// duplicates counts the messages skipped as already stored, by file.
+	duplicates map[string]int
+
+	enc    *Encryption
+	tmpDir string
+}
+
+func (w *sqliteWriter) Begin() error {
+	path, err := w.openPath()
+	if err != nil {
+		return err
+	}
+	db, err := initSQLiteDB(path)
+	if err != nil {
+		w.removeTmp()
+		return err
+	}
+	w.db = db
+	return nil
+}
+
+// openPath returns the database file to write to, decrypting the existing
+// archive first when encrypting.
+func (w *sqliteWriter) openPath() (string, error) {
+	if w.enc == nil {
+		return w.dbName, nil
+	}
+
+	dir, err := os.MkdirTemp("", "gvtakeout-")
+	if err != nil {
+		return "", err
+	}
+	w.tmpDir = dir
+	path := filepath.Join(dir, filepath.Base(w.dbName))
+
+	archive := w.dbName + ".age"
+	if _, err := os.Stat(archive); err == nil {
+		if err := decryptFile(archive, path, w.enc.Identities); err != nil {
+			w.removeTmp()
+			return "", fmt.Errorf("open existing %s: %w", archive, err)
+		}
+	}
+	return path, nil
+}
+
+// Abort closes the database without finishing it. An encrypted database
+// is left as it was before the run.
+func (w *sqliteWriter) Abort() {
+	if w.db == nil {
+		return
+	}
+	w.db.Close()
+	w.db = nil
+	w.removeTmp()
+}
+
+func (w *sqliteWriter) removeTmp() {
+	if w.tmpDir != "" {
+		os.RemoveAll(w.tmpDir)
+		w.tmpDir = ""
+	}
+}
+
+func (w *sqliteWriter) Write(conv Conversation) error {
+	n, err := insertConversation(w.db, conv)
+	if n > 0 {
+		if w.duplicates == nil {
+			w.duplicates = make(map[string]int)
+		}
+		w.duplicates[conv.SourceFile] += n
+	}
+	return err
+}
+
+func (w *sqliteWriter) Close() error {
+	if w.db == nil {
+		return nil
+	}
+	reportDuplicates(w.duplicates)
+	if err := linkCallEvents(w.db); err != nil {
+		w.db.Close()
+		w.removeTmp()
+		return err
+	}
+	if w.enc == nil {
+		return w.db.Close()
+	}
+	defer w.removeTmp()
+
+	// Fold the WAL into the main file so the snapshot is complete.
+	if _, err := w.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		w.db.Close()
+		return fmt.Errorf("checkpoint database: %w", err)
+	}
+	if err := w.db.Close(); err != nil {
+		return err
+	}
+
+	archive := w.dbName + ".age"
+	if err := encryptFile(filepath.Join(w.tmpDir, filepath.Base(w.dbName)), archive, w.enc.Recipients); err != nil {
+		return fmt.Errorf("encrypt %s: %w", archive, err)
+	}
+	slog.Info("encrypted database written", "file", archive)
+	return nil
+}
+
+func initSQLiteDB(dbName string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	_, err = db.Exec("PRAGMA journal_mode=WAL")
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("PRAGMA journal_mode=WAL error: %w", err)
+	}
+
+	if err := createTables(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	slog.Info("SQLite database initialized", "file", dbName)
+	return db, nil
+}
+
+// schemaVersion is kept in the database's user_version. Databases written
+// before it was recorded have version 0.
+const schemaVersion = 1
+
+// createTables creates whatever tables db lacks. A database from an older
+// gvtakeout whose tables lack columns this one writes is refused rather
+// than failing partway through an import.
+func createTables(db *sql.DB) error {
+	if err := checkSchema(db); err != nil {
+		return err
+	}
+	if err := createSchema(db); err != nil {
+		return err
+	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", schemaVersion)); err != nil {
+		return fmt.Errorf("failed to set schema version: %w", err)
+	}
+	return nil
+}
+
+// checkSchema returns an error if db was written by another version of
+// gvtakeout and its tables don't have every column the current schema has.
+func checkSchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	switch {
+	case version == schemaVersion:
+		return nil
+	case version > schemaVersion:
+		return fmt.Errorf("the database was written by a newer version of gvtakeout (schema version %d)", version)
+	}
+
+	want, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return err
+	}
+	defer want.Close()
+	want.SetMaxOpenConns(1)
+	if err := createSchema(want); err != nil {
+		return err
+	}
+	tables, err := queryStrings(want, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, table := range tables {
+		have, err := tableColumns(db, table)
+		if err != nil {
+			return err
+		}
+		if len(have) == 0 {
+			continue
+		}
+		cols, err := tableColumns(want, table)
+		if err != nil {
+			return err
+		}
+		for col := range cols {
+			if !have[col] {
+				missing = append(missing, table+"."+col)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("the database was written by an older version of gvtakeout and lacks columns %s; import into a new database instead",
+			strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// tableColumns returns the columns of table, or none if it doesn't exist.
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	names, err := queryStrings(db, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	cols := make(map[string]bool, len(names))
+	for _, name := range names {
+		cols[name] = true
+	}
+	return cols, nil
+}
+
+func createSchema(db *sql.DB) error {
+	createTableQueries := []string{
+		`CREATE TABLE IF NOT EXISTS contact (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			uid TEXT UNIQUE,
+			account TEXT NOT NULL DEFAULT '',
+			name TEXT,
+			phone_number TEXT,
+			UNIQUE(account, name, phone_number)
+		)`,
+		`CREATE TABLE IF NOT EXISTS contact_detail (
+			contact_id INTEGER PRIMARY KEY,
+			display_name TEXT,
+			organization TEXT,
+			photo_url TEXT,
+			photo_type TEXT,
+			photo_data BLOB,
+			source_file TEXT,
+			FOREIGN KEY (contact_id) REFERENCES contact (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS contact_phone (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			contact_id INTEGER,
+			phone_number TEXT,
+			label TEXT,
+			UNIQUE(contact_id, phone_number),
+			FOREIGN KEY (contact_id) REFERENCES contact (id)
+		)`,
+		`CREATE VIEW IF NOT EXISTS contact_display AS
+			SELECT contact.id, COALESCE(contact.uid, '') AS uid, contact.account,
+				COALESCE(NULLIF(contact_detail.display_name, ''), contact.name) AS name,
+				contact.phone_number,
+				COALESCE(contact_detail.organization, '') AS organization,
+				contact_detail.photo_data IS NOT NULL AS has_photo
+			FROM contact LEFT JOIN contact_detail ON contact_detail.contact_id = contact.id`,
+		`CREATE TABLE IF NOT EXISTS conversation (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			uid TEXT UNIQUE,
+			thread_uid TEXT,
+			account TEXT NOT NULL DEFAULT '',
+			type TEXT,
+			timestamp DATETIME,
+			timestamp_ms INTEGER,
+			utc_offset INTEGER,
+			duration TEXT,
+			transcript TEXT,
+			source_file TEXT,
+			origin TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS conversation_thread_uid ON conversation (thread_uid)`,
+		`CREATE TABLE IF NOT EXISTS participant (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id INTEGER,
+			contact_id INTEGER,
+			is_self INTEGER NOT NULL DEFAULT 0,
+			source TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (conversation_id) REFERENCES conversation (id),
+			FOREIGN KEY (contact_id) REFERENCES contact (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS message (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id INTEGER,
+			timestamp DATETIME,
+			timestamp_ms INTEGER,
+			utc_offset INTEGER,
+			sender_contact_id INTEGER,
+			content TEXT,
+			uid TEXT UNIQUE,
+			FOREIGN KEY (conversation_id) REFERENCES conversation (id),
+			FOREIGN KEY (sender_contact_id) REFERENCES contact (id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS message_timestamp_ms ON message (timestamp_ms)`,
+		`CREATE TABLE IF NOT EXISTS duplicate_message (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			message_id INTEGER,
+			source_file TEXT,
+			UNIQUE(message_id, source_file),
+			FOREIGN KEY (message_id) REFERENCES message (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS image (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			message_id INTEGER,
+			image_url TEXT,
+			FOREIGN KEY (message_id) REFERENCES message (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS media_file (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			image_id INTEGER,
+			file_name TEXT,
+			content BLOB,
+			FOREIGN KEY (image_id) REFERENCES image (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS reaction (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			message_id INTEGER,
+			sender_contact_id INTEGER,
+			timestamp DATETIME,
+			timestamp_ms INTEGER,
+			utc_offset INTEGER,
+			kind TEXT,
+			UNIQUE(message_id, sender_contact_id, kind, timestamp_ms),
+			FOREIGN KEY (message_id) REFERENCES message (id),
+			FOREIGN KEY (sender_contact_id) REFERENCES contact (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS call_event (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account TEXT NOT NULL DEFAULT '',
+			phone_number TEXT,
+			missed_call_id INTEGER UNIQUE,
+			voicemail_id INTEGER UNIQUE,
+			FOREIGN KEY (missed_call_id) REFERENCES conversation (id),
+			FOREIGN KEY (voicemail_id) REFERENCES conversation (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS call_audio (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id INTEGER,
+			audio_url TEXT,
+			file_name TEXT,
+			content BLOB,
+			FOREIGN KEY (conversation_id) REFERENCES conversation (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS billing_entry (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp DATETIME,
+			timestamp_ms INTEGER,
+			utc_offset INTEGER,
+			description TEXT,
+			phone_number TEXT,
+			duration TEXT,
+			amount TEXT,
+			amount_cents INTEGER,
+			currency TEXT,
+			source_file TEXT,
+			account TEXT NOT NULL DEFAULT ''
+		)`,
+		// NULLs never equal each other in a UNIQUE constraint, so a row
+		// without a date would be stored again on every import.
+		`CREATE UNIQUE INDEX IF NOT EXISTS billing_entry_key ON billing_entry
+			(account, COALESCE(timestamp_ms, 0), COALESCE(description, ''), COALESCE(amount_cents, 0))`,
+		`CREATE TABLE IF NOT EXISTS account (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account TEXT NOT NULL DEFAULT '',
+			phone_number TEXT,
+			label TEXT,
+			types TEXT,
+			source_file TEXT,
+			UNIQUE(account, phone_number)
+		)`,
+		`CREATE TABLE IF NOT EXISTS greeting (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			uid TEXT UNIQUE,
+			name TEXT,
+			recorded_at DATETIME,
+			timestamp_ms INTEGER,
+			utc_offset INTEGER,
+			file_name TEXT,
+			content BLOB,
+			account TEXT NOT NULL DEFAULT ''
+		)`,
+	}
+
+	for _, query := range createTableQueries {
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to create table: %w", err)
+		}
+	}
+	return nil
+}
+
+// insertConversation stores a conversation. It first finds the messages that
+// are already stored, which are recorded as duplicates of conv's source file
+// rather than stored again. If a conversation with conv's stable ID is
+// stored, such as the same thread from a later takeout, conv's new messages
+// are added to it and its start moves back to the earliest of them.
+// Reactions are inserted for every message, stored before or not. It returns
+// how many duplicates were recorded that weren't already.
+func insertConversation(db *sql.DB, conv Conversation) (int, error) {
+	// Conversations are stored under the IDs the parser gave them, so the
+	// database and the JSON output agree. Only one built without IDs gets
+	// them here.
+	if conv.ID == "" {
+		conv.assignIDs()
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := findDuplicates(tx, conv)
+	if err != nil {
+		return 0, err
+	}
+
+	var convID int64
+	err = tx.QueryRow("SELECT id FROM conversation WHERE uid = ?", conv.ID).Scan(&convID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to look up conversation: %w", err)
+	}
+	stored := err == nil
+
+	contactStmt, err := tx.Prepare("INSERT OR IGNORE INTO contact (uid, account, name, phone_number) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare contact statement: %w", err)
+	}
+	defer contactStmt.Close()
+
+	// contactIDs are the ids of conv's participants, filled in as they
+	// are stored.
+	contactIDs := make([]int64, len(conv.Participants))
+	storeContact := func(i int) (int64, error) {
+		if contactIDs[i] != 0 {
+			return contactIDs[i], nil
+		}
+		p := conv.Participants[i]
+		if _, err := contactStmt.Exec(p.ContactID, conv.Account, p.Name, p.Number); err != nil {
+			return 0, fmt.Errorf("failed to insert contact: %w", err)
+		}
+		if err := tx.QueryRow("SELECT id FROM contact WHERE uid = ?", p.ContactID).Scan(&contactIDs[i]); err != nil {
+			return 0, fmt.Errorf("failed to get contact ID: %w", err)
+		}
+		return contactIDs[i], nil
+	}
+
+	// Reactions are stored even on messages that already were, since a
+	// later takeout can have reactions an earlier one didn't.
+	reactionStmt, err := tx.Prepare("INSERT OR IGNORE INTO reaction (message_id, sender_contact_id, timestamp, timestamp_ms, utc_offset, kind) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare reaction statement: %w", err)
+	}
+	defer reactionStmt.Close()
+	insertReactions := func(msgID int64, reactions []Reaction) error {
+		for _, r := range reactions {
+			i := conv.Participants.find(r.Sender, r.SenderNumber)
+			if i < 0 {
+				return fmt.Errorf("failed to find contact ID for reaction sender: %s", r.Sender)
+			}
+			contactID, err := storeContact(i)
+			if err != nil {
+				return err
+			}
+			_, err = reactionStmt.Exec(msgID, contactID, r.Timestamp.UTC(), r.Timestamp.UnixMilli(), utcOffset(r.Timestamp), r.Kind)
+			if err != nil {
+				return fmt.Errorf("failed to insert reaction: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if !stored && len(conv.Messages) > 0 && len(existing) == len(conv.Messages) {
+		// The whole thread is already stored, under another conversation.
+		dups := make([]int64, 0, len(existing))
+		for i, msg := range conv.Messages {
+			dups = append(dups, existing[i])
+			if err := insertReactions(existing[i], msg.Reactions); err != nil {
+				return 0, err
+			}
+		}
+		n, err := recordDuplicates(tx, conv.SourceFile, dups)
+		if err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return n, nil
+	}
+
+	if !stored {
+		convStmt, err := tx.Prepare("INSERT INTO conversation (uid, thread_uid, account, type, timestamp, timestamp_ms, utc_offset, duration, transcript, source_file, origin) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		if err != nil {
+			return 0, fmt.Errorf("failed to prepare conversation statement: %w", err)
+		}
+		defer convStmt.Close()
+
+		result, err := convStmt.Exec(conv.ID, conv.ThreadID, conv.Account, conv.Type, conv.Timestamp.UTC(), conv.Timestamp.UnixMilli(), utcOffset(conv.Timestamp), conv.Duration, conv.Transcript, conv.SourceFile, conv.Origin)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert conversation: %w", err)
+		}
+
+		convID, err = result.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+		}
+	}
+
+	// Insert contacts and participants
+	partStmt, err := tx.Prepare("INSERT INTO participant (conversation_id, contact_id, is_self, source) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare participant statement: %w", err)
+	}
+	defer partStmt.Close()
+
+	for i, p := range conv.Participants {
+		contactID, err := storeContact(i)
+		if err != nil {
+			return 0, err
+		}
+
+		if stored {
+			continue
+		}
+		_, err = partStmt.Exec(convID, contactID, p.IsSelf, p.Source)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert participant: %w", err)
+		}
+	}
+
+	// Insert messages and images
+	msgStmt, err := tx.Prepare("INSERT INTO message (conversation_id, timestamp, timestamp_ms, utc_offset, sender_contact_id, content, uid) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare message statement: %w", err)
+	}
+	defer msgStmt.Close()
+
+	imgStmt, err := tx.Prepare("INSERT INTO image (message_id, image_url) VALUES (?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare image statement: %w", err)
+	}
+	defer imgStmt.Close()
+
+	mediaStmt, err := tx.Prepare("INSERT INTO media_file (image_id, file_name, content) VALUES (?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare media file statement: %w", err)
+	}
+	defer mediaStmt.Close()
+
+	var (
+		dups     []int64
+		earliest time.Time
+	)
+	inserted := make(map[string]int64)
+	for mi, msg := range conv.Messages {
+		id, ok := existing[mi]
+		if !ok {
+			id, ok = inserted[msg.ID]
+		}
+		if ok {
+			dups = append(dups, id)
+			if err := insertReactions(id, msg.Reactions); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		i := conv.Participants.find(msg.Sender, msg.SenderNumber)
+		if i < 0 {
+			return 0, fmt.Errorf("failed to find contact ID for sender: %s", msg.Sender)
+		}
+		senderContactID := contactIDs[i]
+
+		msgResult, err := msgStmt.Exec(convID, msg.Timestamp.UTC(), msg.Timestamp.UnixMilli(), utcOffset(msg.Timestamp), senderContactID, msg.Content, msg.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert message: %w", err)
+		}
+
+		msgID, err := msgResult.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get last insert ID for message: %w", err)
+		}
+		inserted[msg.ID] = msgID
+		if earliest.IsZero() || msg.Timestamp.Before(earliest) {
+			earliest = msg.Timestamp
+		}
+
+		for _, img := range msg.Images {
+			imgResult, err := imgStmt.Exec(msgID, img)
+			if err != nil {
+				return 0, fmt.Errorf("failed to insert image: %w", err)
+			}
+
+			imgID, err := imgResult.LastInsertId()
+			if err != nil {
+				return 0, fmt.Errorf("failed to get last insert ID for image: %w", err)
+			}
+			if conv.Origin != "" {
+				// Only takeout media files sit next to the thread files;
+				// other sources' attachments are kept by name alone.
+				continue
+			}
+
+			err = insertMediaFile(tx, mediaStmt, imgID, img)
+			if errors.Is(err, errNoMediaFile) {
+				// A missing attachment shouldn't cost us the rest of the
+				// conversation. The image row still records the reference.
+				slog.Warn("skipping media file", "err", err)
+			} else if err != nil {
+				return 0, fmt.Errorf("failed to insert media file: %w", err)
+			}
+		}
+
+		if err := insertReactions(msgID, msg.Reactions); err != nil {
+			return 0, err
+		}
+	}
+
+	if stored && !earliest.IsZero() {
+		// A conversation starts with its earliest message, which may be
+		// one just added.
+		_, err := tx.Exec(`UPDATE conversation SET timestamp = ?, timestamp_ms = ?, utc_offset = ?
+			WHERE id = ? AND (timestamp_ms IS NULL OR timestamp_ms > ?)`,
+			earliest.UTC(), earliest.UnixMilli(), utcOffset(earliest), convID, earliest.UnixMilli())
+		if err != nil {
+			return 0, fmt.Errorf("failed to update conversation start: %w", err)
+		}
+	}
+
+	if conv.Audio != "" && !stored {
+		if err := insertCallAudio(tx, convID, conv.Audio); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := recordDuplicates(tx, conv.SourceFile, dups)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return n, nil
+}
+
+func (w *sqliteWriter) WriteBillingEntries(entries []BillingEntry) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO billing_entry
+		(account, timestamp, timestamp_ms, utc_offset, description, phone_number, duration, amount, amount_cents, currency, source_file)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare billing entry statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		ts, ms, offset := timestampColumns(e.Timestamp)
+		_, err := stmt.Exec(e.Account, ts, ms, offset, e.Description, e.Number, e.Duration, e.Amount, e.AmountCents, e.Currency, e.SourceFile)
+		if err != nil {
+			return fmt.Errorf("failed to insert billing entry: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (w *sqliteWriter) WriteAccountPhones(phones []AccountPhone) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO account (account, phone_number, label, types, source_file) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (account, phone_number) DO UPDATE SET label = excluded.label, types = excluded.types, source_file = excluded.source_file`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare account statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range phones {
+		_, err := stmt.Exec(p.Account, p.PhoneNumber, p.Label, strings.Join(p.Types, ","), p.SourceFile)
+		if err != nil {
+			return fmt.Errorf("failed to insert account phone: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (w *sqliteWriter) WriteGreetings(greetings []Greeting) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Updating a stored greeting in place keeps its row, unlike INSERT OR
+	// REPLACE.
+	stmt, err := tx.Prepare(`INSERT INTO greeting (uid, account, name, recorded_at, timestamp_ms, utc_offset, file_name, content)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (uid) DO UPDATE SET name = excluded.name, recorded_at = excluded.recorded_at, timestamp_ms = excluded.timestamp_ms,
+			utc_offset = excluded.utc_offset, file_name = excluded.file_name, content = excluded.content`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare greeting statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, g := range greetings {
+		content, err := os.ReadFile(g.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read greeting: %w", err)
+		}
+		ts, ms, offset := timestampColumns(g.RecordedAt)
+		_, err = stmt.Exec(greetingUID(g), g.Account, g.Name, ts, ms, offset, g.Path, content)
+		if err != nil {
+			return fmt.Errorf("failed to insert greeting: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// timestampColumns returns the values for a timestamp, timestamp_ms,
+// utc_offset column triple, or NULLs if t is unset.
+func timestampColumns(t time.Time) (any, any, any) {
+	if t.IsZero() {
+		return nil, nil, nil
+	}
+	return t.UTC(), t.UnixMilli(), utcOffset(t)
+}
+
+// utcOffset returns the offset of t from UTC in seconds, as rendered in the takeout.
+func utcOffset(t time.Time) int {
+	_, offset := t.Zone()
+	return offset
+}
+
+// insertCallAudio stores the recording of a voicemail or recorded call. As
+// with images, the reference is kept even if the takeout lacks the file.
+func insertCallAudio(tx *sql.Tx, convID int64, audioURL string) error {
+	var fileName, content any
+
+	fullPath := audioURL
+	_, err := os.Stat(fullPath)
+	if err != nil {
+		fullPath, err = findMediaFile(audioURL)
+	}
+	switch {
+	case errors.Is(err, errNoMediaFile):
+		slog.Warn("skipping audio file", "err", err)
+	case err != nil:
+		return fmt.Errorf("failed to find audio file: %w", err)
+	default:
+		b, err := os.ReadFile(fullPath)
+		if err != nil {
+			return fmt.Errorf("failed to read audio file: %w", err)
+		}
+		fileName, content = fullPath, b
+	}
+
+	_, err = tx.Exec("INSERT INTO call_audio (conversation_id, audio_url, file_name, content) VALUES (?, ?, ?, ?)", convID, audioURL, fileName, content)
+	if err != nil {
+		return fmt.Errorf("failed to insert call audio: %w", err)
+	}
+	return nil
+}
+
+func insertMediaFile(tx *sql.Tx, stmt *sql.Stmt, imgID int64, imageURL string) error {
+	fullPath, err := findMediaFile(imageURL)
+	if err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to read media file: %w", err)
+	}
+
+	_, err = stmt.Exec(imgID, fullPath, content)
+	if err != nil {
+		return fmt.Errorf("failed to insert media file: %w", err)
+	}
+
+	return nil
+}
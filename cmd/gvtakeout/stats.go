@@ -0,0 +1,206 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func runStats(args []string) error {
+	fs, common := newFlagSet("stats", "")
+	account := fs.String("account", "", "Only count this account")
+	fs.Parse(args)
+
+	loc, err := common.setup()
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := common.openDB()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	st, err := collectStats(db, *account)
+	if err != nil {
+		return err
+	}
+	return st.print(os.Stdout, loc)
+}
+
+// dbStats summarizes the contents of a database.
+type dbStats struct {
+	Accounts       []string
+	Types          map[string]int
+	Conversations  int
+	Messages       int
+	Images         int
+	MediaFiles     int
+	Reactions      int
+	Duplicates     int
+	Contacts       int
+	Greetings      int
+	BillingEntries int
+	First, Last    time.Time
+	TopContacts    []contactCount
+}
+
+type contactCount struct {
+	Name     string
+	Number   string
+	Messages int
+}
+
+func collectStats(db *sql.DB, account string) (*dbStats, error) {
+	st := dbStats{Types: make(map[string]int)}
+
+	rows, err := db.Query("SELECT type, COUNT(*) FROM conversation WHERE (? = '' OR account = ?) GROUP BY type", account, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count conversations: %w", err)
+	}
+	for rows.Next() {
+		var (
+			typ string
+			n   int
+		)
+		if err := rows.Scan(&typ, &n); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan conversation count: %w", err)
+		}
+		st.Types[typ] = n
+		st.Conversations += n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating conversation counts: %w", err)
+	}
+
+	counts := []struct {
+		dst   *int
+		query string
+	}{
+		{&st.Messages, "SELECT COUNT(*) FROM message JOIN conversation ON conversation.id = message.conversation_id WHERE (? = '' OR conversation.account = ?)"},
+		{&st.Images, "SELECT COUNT(*) FROM image JOIN message ON message.id = image.message_id JOIN conversation ON conversation.id = message.conversation_id WHERE (? = '' OR conversation.account = ?)"},
+		{&st.MediaFiles, "SELECT COUNT(*) FROM media_file JOIN image ON image.id = media_file.image_id JOIN message ON message.id = image.message_id JOIN conversation ON conversation.id = message.conversation_id WHERE (? = '' OR conversation.account = ?)"},
+		{&st.Reactions, "SELECT COUNT(*) FROM reaction JOIN message ON message.id = reaction.message_id JOIN conversation ON conversation.id = message.conversation_id WHERE (? = '' OR conversation.account = ?)"},
+		{&st.Duplicates, "SELECT COUNT(*) FROM duplicate_message JOIN message ON message.id = duplicate_message.message_id JOIN conversation ON conversation.id = message.conversation_id WHERE (? = '' OR conversation.account = ?)"},
+		{&st.Contacts, "SELECT COUNT(*) FROM contact WHERE name != 'Me' AND (? = '' OR account = ?)"},
+		{&st.Greetings, "SELECT COUNT(*) FROM greeting WHERE (? = '' OR account = ?)"},
+		{&st.BillingEntries, "SELECT COUNT(*) FROM billing_entry WHERE (? = '' OR account = ?)"},
+	}
+	for _, c := range counts {
+		if err := db.QueryRow(c.query, account, account).Scan(c.dst); err != nil {
+			return nil, fmt.Errorf("failed to count: %w", err)
+		}
+	}
+
+	var first, last sql.NullInt64
+	err = db.QueryRow(`SELECT MIN(ms), MAX(ms) FROM (
+			SELECT timestamp_ms AS ms FROM conversation WHERE (? = '' OR account = ?)
+			UNION ALL
+			SELECT message.timestamp_ms FROM message JOIN conversation ON conversation.id = message.conversation_id WHERE (? = '' OR conversation.account = ?)
+		)`, account, account, account, account).Scan(&first, &last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activity range: %w", err)
+	}
+	if first.Valid {
+		st.First = time.UnixMilli(first.Int64)
+		st.Last = time.UnixMilli(last.Int64)
+	}
+
+	st.Accounts, err = queryStrings(db, "SELECT DISTINCT account FROM conversation WHERE account != '' ORDER BY account")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`SELECT contact.name, contact.phone_number, COUNT(*) AS n
+		FROM message
+		JOIN contact ON contact.id = message.sender_contact_id
+		JOIN conversation ON conversation.id = message.conversation_id
+		WHERE contact.name != 'Me' AND (? = '' OR conversation.account = ?)
+		GROUP BY contact.name, contact.phone_number
+		ORDER BY n DESC, contact.name
+		LIMIT 10`, account, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top contacts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c contactCount
+		if err := rows.Scan(&c.Name, &c.Number, &c.Messages); err != nil {
+			return nil, fmt.Errorf("failed to scan contact count: %w", err)
+		}
+		st.TopContacts = append(st.TopContacts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating contact counts: %w", err)
+	}
+
+	return &st, nil
+}
+
+func queryStrings(db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (st *dbStats) print(out io.Writer, loc *time.Location) error {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Conversations:\t%d\n", st.Conversations)
+
+	types := make([]string, 0, len(st.Types))
+	for t := range st.Types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return st.Types[types[i]] > st.Types[types[j]] || st.Types[types[i]] == st.Types[types[j]] && types[i] < types[j]
+	})
+	for _, t := range types {
+		fmt.Fprintf(tw, "  %s\t%d\n", t, st.Types[t])
+	}
+
+	fmt.Fprintf(tw, "Messages:\t%d\n", st.Messages)
+	fmt.Fprintf(tw, "Images:\t%d (%d with media)\n", st.Images, st.MediaFiles)
+	fmt.Fprintf(tw, "Reactions:\t%d\n", st.Reactions)
+	fmt.Fprintf(tw, "Duplicates skipped:\t%d\n", st.Duplicates)
+	fmt.Fprintf(tw, "Contacts:\t%d\n", st.Contacts)
+	fmt.Fprintf(tw, "Greetings:\t%d\n", st.Greetings)
+	fmt.Fprintf(tw, "Billing entries:\t%d\n", st.BillingEntries)
+	if !st.First.IsZero() {
+		fmt.Fprintf(tw, "Activity:\t%s to %s\n", st.First.In(loc).Format("2006-01-02"), st.Last.In(loc).Format("2006-01-02"))
+	}
+	if len(st.Accounts) > 0 {
+		fmt.Fprintf(tw, "Accounts:\t%s\n", strings.Join(st.Accounts, ", "))
+	}
+
+	if len(st.TopContacts) > 0 {
+		fmt.Fprintf(tw, "\nTop contacts by messages:\n")
+		for _, c := range st.TopContacts {
+			fmt.Fprintf(tw, "  %s (%s)\t%d\n", c.Name, c.Number, c.Messages)
+		}
+	}
+	return tw.Flush()
+}
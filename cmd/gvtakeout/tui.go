@@ -0,0 +1,730 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+func runBrowse(args []string) error {
+	fs, common := newFlagSet("browse", "")
+	account := fs.String("account", "", "Only list conversations from this account")
+	fs.Parse(args)
+
+	var err error
+	displayLoc, err = common.setup()
+	if err != nil {
+		return err
+	}
+
+	inFd, outFd := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		return fmt.Errorf("browse needs a terminal; use search or show to script")
+	}
+
+	var closeDB func()
+	db, closeDB, err = common.openDB()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	groups, err := getGroups(*account)
+	if err != nil {
+		return err
+	}
+	b := newBrowser(groups, func(g Group) ([]threadConv, error) {
+		return loadThread(g, *account)
+	})
+
+	oldState, err := term.MakeRaw(inFd)
+	if err != nil {
+		return fmt.Errorf("failed to put terminal in raw mode: %w", err)
+	}
+	defer term.Restore(inFd, oldState)
+
+	out := bufio.NewWriter(os.Stdout)
+	// Switch to the alternate screen and hide the cursor, restoring both on
+	// exit so the shell's scrollback is left as it was.
+	out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		out.WriteString("\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	buf := make([]byte, 256)
+	for !b.quit {
+		// Checking the size on every redraw keeps up with resizes without
+		// needing SIGWINCH, which Windows doesn't have.
+		if w, h, err := term.GetSize(outFd); err == nil {
+			b.width, b.height = w, h
+		}
+		b.render(out)
+		if err := out.Flush(); err != nil {
+			return err
+		}
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			b.handleKey(k)
+		}
+	}
+	return nil
+}
+
+// threadConv is a conversation shown in the thread view along with
+// metadata about its image and audio attachments, keyed by URL.
+type threadConv struct {
+	ID          int64
+	Conv        Conversation
+	Attachments map[string]attachment
+}
+
+type attachment struct {
+	FileName string
+	Size     int64
+	// Stored is false when the takeout didn't include the media file.
+	Stored bool
+}
+
+// loadThread loads every conversation of account in a group, oldest first.
+func loadThread(g Group, account string) ([]threadConv, error) {
+	ids, err := getConversationIDsForGroup(g.Key, account)
+	if err != nil {
+		return nil, err
+	}
+
+	var thread []threadConv
+	for _, id := range ids {
+		conv, err := loadConversation(db, int64(id))
+		if err != nil {
+			return nil, err
+		}
+		if displayLoc != nil {
+			conv = conv.In(displayLoc)
+		}
+		atts, err := getAttachments(db, int64(id))
+		if err != nil {
+			return nil, err
+		}
+		thread = append(thread, threadConv{ID: int64(id), Conv: conv, Attachments: atts})
+	}
+	sort.SliceStable(thread, func(i, j int) bool {
+		return thread[i].Conv.Timestamp.Before(thread[j].Conv.Timestamp)
+	})
+	return thread, nil
+}
+
+// getAttachments returns the media file metadata for the images and call
+// audio in a conversation.
+func getAttachments(db *sql.DB, convID int64) (map[string]attachment, error) {
+	rows, err := db.Query(`SELECT image.image_url, COALESCE(media_file.file_name, ''), COALESCE(LENGTH(media_file.content), 0), media_file.id IS NOT NULL
+		FROM image
+		JOIN message ON message.id = image.message_id
+		LEFT JOIN media_file ON media_file.image_id = image.id
+		WHERE message.conversation_id = ?
+		UNION ALL
+		SELECT audio_url, COALESCE(file_name, ''), COALESCE(LENGTH(content), 0), content IS NOT NULL
+		FROM call_audio
+		WHERE conversation_id = ?`, convID, convID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %w", err)
+	}
+	defer rows.Close()
+
+	atts := make(map[string]attachment)
+	for rows.Next() {
+		var (
+			url string
+			a   attachment
+		)
+		if err := rows.Scan(&url, &a.FileName, &a.Size, &a.Stored); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment row: %w", err)
+		}
+		atts[url] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachment rows: %w", err)
+	}
+	return atts, nil
+}
+
+type browserMode int
+
+const (
+	listMode browserMode = iota
+	threadMode
+)
+
+// lineKind selects how a thread line is drawn.
+type lineKind int
+
+const (
+	bodyLine lineKind = iota
+	headerLine
+	metaLine
+)
+
+// tuiLine is one line of the thread view. Lines are kept as plain text and
+// styled when drawn so that searching and truncation ignore escape codes.
+type tuiLine struct {
+	text string
+	kind lineKind
+	// sender, if set, starts at byte senderAt of text and is colored.
+	sender   string
+	senderAt int
+}
+
+// browser is the state of the terminal UI. It is driven by handleKey and
+// drawn by render, independent of the terminal itself.
+type browser struct {
+	groups     []Group
+	loadThread func(Group) ([]threadConv, error)
+	width      int
+	height     int
+	mode       browserMode
+	quit       bool
+	status     string
+
+	// List view: filtered holds the indexes of the groups matching filter.
+	filter   string
+	filtered []int
+	sel      int
+	top      int
+
+	// Thread view.
+	title     string
+	thread    []threadConv
+	lines     []tuiLine
+	wrappedAt int
+	scroll    int
+	match     int
+	query     string
+
+	// searching is set while typing a / search; input is the text so far
+	// and savedScroll is restored if the search is cancelled.
+	searching   bool
+	input       string
+	savedScroll int
+	savedSel    int
+}
+
+func newBrowser(groups []Group, load func(Group) ([]threadConv, error)) *browser {
+	b := &browser{groups: groups, loadThread: load, width: 80, height: 24, match: -1}
+	b.applyFilter("")
+	return b
+}
+
+// pageSize is the number of content rows between the title and status
+// lines.
+func (b *browser) pageSize() int {
+	return max(b.height-2, 1)
+}
+
+func (b *browser) handleKey(k string) {
+	b.status = ""
+	if k == "ctrl-c" {
+		b.quit = true
+		return
+	}
+	if b.searching {
+		b.searchKey(k)
+		return
+	}
+	if b.mode == listMode {
+		b.listKey(k)
+	} else {
+		b.threadKey(k)
+	}
+}
+
+func (b *browser) listKey(k string) {
+	switch k {
+	case "q":
+		b.quit = true
+	case "up", "k":
+		b.sel--
+	case "down", "j":
+		b.sel++
+	case "pgup":
+		b.sel -= b.pageSize()
+	case "pgdn", " ":
+		b.sel += b.pageSize()
+	case "home", "g":
+		b.sel = 0
+	case "end", "G":
+		b.sel = len(b.filtered) - 1
+	case "esc":
+		b.applyFilter("")
+	case "/":
+		b.searching, b.input, b.savedSel = true, b.filter, b.sel
+	case "enter":
+		if len(b.filtered) > 0 {
+			b.openThread(b.groups[b.filtered[b.sel]])
+		}
+	}
+	b.clampList()
+}
+
+func (b *browser) threadKey(k string) {
+	switch k {
+	case "q", "esc", "backspace", "left", "h":
+		b.mode = listMode
+		b.thread, b.lines = nil, nil
+	case "up", "k":
+		b.scroll--
+	case "down", "j", "enter":
+		b.scroll++
+	case "pgup", "b":
+		b.scroll -= b.pageSize()
+	case "pgdn", " ":
+		b.scroll += b.pageSize()
+	case "home", "g":
+		b.scroll = 0
+	case "end", "G":
+		b.scroll = len(b.lines)
+	case "/":
+		b.searching, b.input, b.savedScroll = true, "", b.scroll
+	case "n":
+		b.findLine(b.query, b.match+1, 1)
+	case "N":
+		b.findLine(b.query, b.match-1, -1)
+	}
+	b.clampThread()
+}
+
+// searchKey edits the / prompt. Searching is incremental: the list is
+// filtered, or the thread scrolled to the next match, on every keystroke.
+func (b *browser) searchKey(k string) {
+	switch k {
+	case "enter":
+		b.searching = false
+		if b.mode == threadMode {
+			b.query = b.input
+			if b.match < 0 && b.query != "" {
+				b.status = "not found: " + b.query
+			}
+		}
+		return
+	case "esc":
+		b.searching = false
+		if b.mode == listMode {
+			b.applyFilter("")
+			b.sel = b.savedSel
+			b.clampList()
+		} else {
+			b.scroll, b.match = b.savedScroll, -1
+		}
+		return
+	case "backspace":
+		if b.input != "" {
+			_, size := utf8.DecodeLastRuneInString(b.input)
+			b.input = b.input[:len(b.input)-size]
+		}
+	default:
+		if utf8.RuneCountInString(k) != 1 {
+			return
+		}
+		b.input += k
+	}
+
+	if b.mode == listMode {
+		b.applyFilter(b.input)
+		b.sel = 0
+		b.clampList()
+	} else {
+		b.scroll = b.savedScroll
+		b.findLine(b.input, b.savedScroll, 1)
+		b.clampThread()
+	}
+}
+
+// applyFilter lists the groups whose participants, type, or latest messages
+// contain filter, case insensitively.
+func (b *browser) applyFilter(filter string) {
+	b.filter = filter
+	b.filtered = b.filtered[:0]
+	q := strings.ToLower(filter)
+	for i, g := range b.groups {
+		if q == "" || strings.Contains(strings.ToLower(groupSearchText(g)), q) {
+			b.filtered = append(b.filtered, i)
+		}
+	}
+}
+
+func groupSearchText(g Group) string {
+	var sb strings.Builder
+	sb.WriteString(g.Type)
+	for _, p := range g.Participants {
+		sb.WriteString("\n" + p.Name + "\n" + p.PhoneNumber)
+	}
+	if g.LastMessage != nil {
+		sb.WriteString("\n" + g.LastMessage.Content)
+	}
+	return sb.String()
+}
+
+func (b *browser) clampList() {
+	b.sel = max(min(b.sel, len(b.filtered)-1), 0)
+	if b.sel < b.top {
+		b.top = b.sel
+	}
+	if b.sel >= b.top+b.pageSize() {
+		b.top = b.sel - b.pageSize() + 1
+	}
+}
+
+func (b *browser) clampThread() {
+	b.scroll = max(min(b.scroll, len(b.lines)-b.pageSize()), 0)
+}
+
+func (b *browser) openThread(g Group) {
+	thread, err := b.loadThread(g)
+	if err != nil {
+		b.status = err.Error()
+		return
+	}
+	b.mode = threadMode
+	b.title = groupNames(g)
+	b.thread = thread
+	b.wrappedAt = 0
+	b.rewrap()
+	// Open at the most recent messages, like a chat app.
+	b.scroll = len(b.lines)
+	b.match, b.query = -1, ""
+	b.clampThread()
+}
+
+// rewrap lays out the thread for the current width.
+func (b *browser) rewrap() {
+	if b.wrappedAt == b.width {
+		return
+	}
+	b.lines = threadLines(b.thread, b.width)
+	b.wrappedAt = b.width
+	b.match = -1
+	b.clampThread()
+}
+
+// findLine moves the match to the first line from start in direction dir
+// containing q, scrolling it into view. The search wraps around.
+func (b *browser) findLine(q string, start, dir int) {
+	if q == "" || len(b.lines) == 0 {
+		b.match = -1
+		return
+	}
+	q = strings.ToLower(q)
+	for i := range b.lines {
+		n := ((start+i*dir)%len(b.lines) + len(b.lines)) % len(b.lines)
+		if strings.Contains(strings.ToLower(b.lines[n].text), q) {
+			b.match = n
+			if n < b.scroll || n >= b.scroll+b.pageSize() {
+				b.scroll = n - b.pageSize()/2
+			}
+			return
+		}
+	}
+	b.match = -1
+}
+
+// msgIndent lines continuation text up under the message after the
+// "2006-01-02 15:04  " timestamp.
+const msgIndent = 18
+
+// threadLines lays out a thread's conversations as lines no wider than
+// width.
+func threadLines(thread []threadConv, width int) []tuiLine {
+	var lines []tuiLine
+	for _, tc := range thread {
+		c := tc.Conv
+		header := fmt.Sprintf("-- %s %s  %s", c.Type, c.ID, c.Timestamp.Format("2006-01-02 15:04"))
+		if c.Duration != "" {
+			header += "  (" + c.Duration + ")"
+		}
+		lines = append(lines, tuiLine{text: header, kind: headerLine})
+
+		if len(c.Messages) == 0 {
+			for _, p := range c.Participants {
+				lines = append(lines, tuiLine{text: "  " + p.Name + " " + p.Number, kind: metaLine})
+			}
+		}
+		if c.Audio != "" {
+			lines = append(lines, tuiLine{text: "  " + describeAttachment("audio", c.Audio, tc.Attachments[c.Audio]), kind: metaLine})
+		}
+		if c.Transcript != "" {
+			for _, l := range wrapText(c.Transcript, width-2) {
+				lines = append(lines, tuiLine{text: "  " + l})
+			}
+		}
+
+		for _, m := range c.Messages {
+			body := wrapText(m.Sender+": "+m.Content, width-msgIndent)
+			lines = append(lines, tuiLine{
+				text:     m.Timestamp.Format("2006-01-02 15:04") + "  " + body[0],
+				sender:   m.Sender,
+				senderAt: msgIndent,
+			})
+			for _, l := range body[1:] {
+				lines = append(lines, tuiLine{text: strings.Repeat(" ", msgIndent) + l})
+			}
+			for _, img := range m.Images {
+				lines = append(lines, tuiLine{text: strings.Repeat(" ", msgIndent) + describeAttachment("image", img, tc.Attachments[img]), kind: metaLine})
+			}
+			if len(m.Reactions) > 0 {
+				lines = append(lines, tuiLine{text: strings.Repeat(" ", msgIndent) + describeReactions(m.Reactions), kind: metaLine})
+			}
+		}
+		lines = append(lines, tuiLine{})
+	}
+	return lines
+}
+
+func describeAttachment(kind, url string, a attachment) string {
+	if !a.Stored {
+		return "[" + kind + " " + url + ": not in takeout]"
+	}
+	return fmt.Sprintf("[%s %s, %s]", kind, a.FileName, formatSize(a.Size))
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return strconv.FormatInt(n, 10) + " B"
+}
+
+// wrapText wraps s at word boundaries into lines of at most width runes,
+// breaking words longer than a line. It always returns at least one line.
+func wrapText(s string, width int) []string {
+	width = max(width, 10)
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			for utf8.RuneCountInString(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				r := []rune(word)
+				lines = append(lines, string(r[:width]))
+				word = string(r[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// truncate cuts s to at most width runes.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	if width <= 1 {
+		return string(r[:max(width, 0)])
+	}
+	return string(r[:width-1]) + "…"
+}
+
+func groupNames(g Group) string {
+	var names []string
+	for _, p := range g.Participants {
+		if !p.IsSelf {
+			names = append(names, p.Name)
+		}
+	}
+	if len(names) == 0 {
+		return selfName
+	}
+	return strings.Join(names, ", ")
+}
+
+func groupPreview(g Group) string {
+	if g.LastMessage == nil {
+		return ""
+	}
+	return g.LastMessage.SenderName + ": " + oneLine(g.LastMessage.Content)
+}
+
+// groupSummary counts a group's messages, calls, and voicemails, e.g.
+// "12 messages, 1 voicemail".
+func groupSummary(g Group) string {
+	var parts []string
+	switch {
+	case g.MessageCount == 1:
+		parts = append(parts, "1 message")
+	case g.MessageCount > 1:
+		parts = append(parts, strconv.Itoa(g.MessageCount)+" messages")
+	}
+	for _, b := range g.Badges {
+		parts = append(parts, b.Label())
+	}
+	return strings.Join(parts, ", ")
+}
+
+const (
+	clearLine = "\x1b[K"
+	reverse   = "\x1b[7m"
+	reset     = "\x1b[0m"
+)
+
+// render redraws the whole screen.
+func (b *browser) render(w io.Writer) {
+	if b.mode == threadMode {
+		b.rewrap()
+	}
+	p := palette{enabled: true}
+	rows := b.pageSize()
+
+	fmt.Fprint(w, "\x1b[H")
+	var title string
+	if b.mode == listMode {
+		title = fmt.Sprintf("gvtakeout  %d threads", len(b.filtered))
+		if b.filter != "" {
+			title += fmt.Sprintf(" matching %q", b.filter)
+		}
+	} else {
+		title = b.title
+	}
+	fmt.Fprint(w, reverse+padRight(truncate(title, b.width), b.width)+reset+"\r\n")
+
+	for row := 0; row < rows; row++ {
+		if b.mode == listMode {
+			i := b.top + row
+			if i < len(b.filtered) {
+				line := truncate(listLine(b.groups[b.filtered[i]]), b.width)
+				if i == b.sel {
+					line = reverse + padRight(line, b.width) + reset
+				}
+				fmt.Fprint(w, line)
+			}
+		} else {
+			i := b.scroll + row
+			if i < len(b.lines) {
+				fmt.Fprint(w, b.drawLine(b.lines[i], i == b.match, p))
+			}
+		}
+		fmt.Fprint(w, clearLine+"\r\n")
+	}
+
+	var status string
+	switch {
+	case b.searching:
+		status = "/" + b.input
+	case b.status != "":
+		status = b.status
+	case b.mode == listMode:
+		status = "j/k move  enter open  / filter  esc clear  q quit"
+	default:
+		status = fmt.Sprintf("j/k scroll  / search  n/N next/prev  q back  %d/%d", min(b.scroll+rows, len(b.lines)), len(b.lines))
+	}
+	fmt.Fprint(w, truncate(status, b.width)+clearLine)
+}
+
+func (b *browser) drawLine(l tuiLine, match bool, p palette) string {
+	text := truncate(l.text, b.width)
+	switch {
+	case match:
+		return reverse + text + reset
+	case l.kind == headerLine, l.kind == metaLine:
+		return p.dim(text)
+	case l.sender != "" && len(text) >= l.senderAt+len(l.sender) && text[l.senderAt:l.senderAt+len(l.sender)] == l.sender:
+		return text[:l.senderAt] + p.sender(l.sender) + text[l.senderAt+len(l.sender):]
+	}
+	return text
+}
+
+func listLine(g Group) string {
+	line := g.Timestamp.Format("2006-01-02 15:04") + "  " + groupNames(g)
+	if summary := groupSummary(g); summary != "" {
+		line += " (" + summary + ")"
+	}
+	return line + "  " + groupPreview(g)
+}
+
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// parseKeys decodes terminal input into key names: printable characters
+// are themselves, and special keys are named, e.g. "up", "pgdn", "enter".
+func parseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			if len(b) == 1 {
+				return append(keys, "esc")
+			}
+			if b[1] != '[' && b[1] != 'O' {
+				keys = append(keys, "esc")
+				b = b[1:]
+				continue
+			}
+			// A CSI or SS3 sequence ends at its first byte in 0x40-0x7e.
+			end := 2
+			for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+				end++
+			}
+			if end == len(b) {
+				return keys
+			}
+			if k, ok := escapeKeys[string(b[2:end+1])]; ok {
+				keys = append(keys, k)
+			}
+			b = b[end+1:]
+			continue
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+		case c == 0x03:
+			keys = append(keys, "ctrl-c")
+		case c < 0x20:
+		default:
+			r, size := utf8.DecodeRune(b)
+			if r != utf8.RuneError {
+				keys = append(keys, string(r))
+			}
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+var escapeKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left",
+	"H": "home", "F": "end",
+	"1~": "home", "7~": "home", "4~": "end", "8~": "end",
+	"5~": "pgup", "6~": "pgdn",
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKeys(t *testing.T) {
+	got := parseKeys([]byte("j/é\r\x1b[A\x1b[6~\x1bOB\x7f\x03\x1b"))
+	want := []string{"j", "/", "é", "enter", "up", "pgdn", "down", "backspace", "ctrl-c", "esc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	got := wrapText("the quick brown fox\njumps over the lazy dog, supercalifragilistic", 12)
+	want := []string{"the quick", "brown fox", "jumps over", "the lazy", "dog,", "supercalifra", "gilistic"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestBrowser(t *testing.T) {
+	ts := time.Date(2024, 5, 22, 21, 48, 0, 0, time.UTC)
+	groups := []Group{
+		{Type: "chat", Timestamp: ts, Participants: []storedParticipant{{Name: "Tony Smehrik"}, {Name: "Me", IsSelf: true}},
+			MessageCount: 1, LastMessage: &storedMessage{SenderName: "Tony Smehrik", Content: "Thank you"}},
+		{Type: "voicemail", Timestamp: ts.Add(-time.Hour), Participants: []storedParticipant{{Name: "Sleve Mcdichael"}},
+			Badges: []groupBadge{{Type: "voicemail", Count: 1}}},
+	}
+
+	var thread []threadConv
+	for i := 0; i < 30; i++ {
+		thread = append(thread, threadConv{ID: int64(i), Conv: Conversation{
+			Type:      "chat",
+			Timestamp: ts.Add(time.Duration(i) * time.Minute),
+			Messages:  []Message{{Timestamp: ts, Sender: "Tony Smehrik", Content: "message " + string(rune('a'+i%26)), Images: []string{"img"}}},
+		}, Attachments: map[string]attachment{"img": {FileName: "img.jpg", Size: 2048, Stored: true}}})
+	}
+	var opened Group
+	b := newBrowser(groups, func(g Group) ([]threadConv, error) {
+		opened = g
+		return thread, nil
+	})
+
+	keys := func(s string) {
+		for _, k := range parseKeys([]byte(s)) {
+			b.handleKey(k)
+		}
+	}
+
+	keys("/sleve")
+	if len(b.filtered) != 1 || b.groups[b.filtered[0]].Type != "voicemail" {
+		t.Fatalf("Expected the filter to match the voicemail, got %v", b.filtered)
+	}
+	keys("\x1b")
+	if len(b.filtered) != 2 || b.searching {
+		t.Fatalf("Expected esc to clear the filter, got %v", b.filtered)
+	}
+
+	keys("\r")
+	if b.mode != threadMode || opened.Type != "chat" {
+		t.Fatalf("Expected the chat to open, got mode %v, %+v", b.mode, opened)
+	}
+	if b.scroll != len(b.lines)-b.pageSize() {
+		t.Errorf("Expected the thread to open at the bottom, got scroll %d of %d", b.scroll, len(b.lines))
+	}
+
+	keys("g/message c\r")
+	if b.match < 0 || !strings.Contains(b.lines[b.match].text, "message c") {
+		t.Fatalf("Expected a match for message c, got %d", b.match)
+	}
+	first := b.match
+	keys("n")
+	if b.match <= first || !strings.Contains(b.lines[b.match].text, "message c") {
+		t.Errorf("Expected n to find the next match after %d, got %d", first, b.match)
+	}
+
+	var buf bytes.Buffer
+	b.render(&buf)
+	if !strings.Contains(buf.String(), "[image img.jpg, 2.0 KB]") {
+		t.Errorf("Expected attachment metadata in the thread, got:\n%s", buf.String())
+	}
+
+	keys("q")
+	if b.mode != listMode {
+		t.Errorf("Expected q to return to the list")
+	}
+	keys("q")
+	if !b.quit {
+		t.Errorf("Expected q to quit from the list")
+	}
+}
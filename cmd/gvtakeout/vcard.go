@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// vcard is a single BEGIN:VCARD ... END:VCARD block.
+type vcard []vcardProperty
+
+// vcardProperty is one content line, e.g. "item1.TEL;TYPE=CELL:+15555550100".
+type vcardProperty struct {
+	Group  string
+	Name   string
+	Params map[string][]string
+	Value  string
+}
+
+// Get returns the first value for the named property, or "".
+func (c vcard) Get(name string) string {
+	for _, p := range c {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// All returns every property with the given name.
+func (c vcard) All(name string) []vcardProperty {
+	var props []vcardProperty
+	for _, p := range c {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// GroupValue returns the value of the named property sharing a group with p,
+// which is how Google attaches labels (X-ABLabel) to numbers.
+func (c vcard) GroupValue(p vcardProperty, name string) string {
+	if p.Group == "" {
+		return ""
+	}
+	for _, other := range c {
+		if other.Group == p.Group && other.Name == name {
+			return other.Value
+		}
+	}
+	return ""
+}
+
+// parseVCards reads all vCards from r. It understands line folding and
+// property groups and parameters, which is all Google's exports use.
+func parseVCards(r io.Reader) ([]vcard, error) {
+	var (
+		cards   []vcard
+		current vcard
+		inCard  bool
+		lines   []string
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prop, err := parseVCardLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VCARD"):
+			inCard = true
+			current = nil
+		case prop.Name == "END" && strings.EqualFold(prop.Value, "VCARD"):
+			if inCard {
+				cards = append(cards, current)
+			}
+			inCard = false
+		case inCard:
+			current = append(current, prop)
+		}
+	}
+
+	return cards, nil
+}
+
+func parseVCardLine(line string) (vcardProperty, error) {
+	var prop vcardProperty
+
+	colon := strings.IndexByte(line, ':')
+	if colon < 0 {
+		return prop, fmt.Errorf("missing ':' in %q", line)
+	}
+	head, value := line[:colon], line[colon+1:]
+
+	parts := strings.Split(head, ";")
+	name := parts[0]
+	if dot := strings.IndexByte(name, '.'); dot >= 0 {
+		prop.Group = name[:dot]
+		name = name[dot+1:]
+	}
+	prop.Name = strings.ToUpper(name)
+
+	for _, param := range parts[1:] {
+		key, val, found := strings.Cut(param, "=")
+		if !found {
+			// vCard 2.1 style bare types, e.g. TEL;CELL:...
+			key, val = "TYPE", param
+		}
+		if prop.Params == nil {
+			prop.Params = make(map[string][]string)
+		}
+		key = strings.ToUpper(key)
+		prop.Params[key] = append(prop.Params[key], strings.Split(val, ",")...)
+	}
+
+	prop.Value = unescapeVCardValue(value)
+	return prop, nil
+}
+
+func unescapeVCardValue(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	r := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return r.Replace(s)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func runVerify(args []string) error {
+	fs, common := newFlagSet("verify", "")
+	takeout := fs.String("takeout", "", "Also check that every HTML file in this takeout directory was imported")
+	fs.Parse(args)
+
+	if _, err := common.setup(); err != nil {
+		return err
+	}
+
+	db, closeDB, err := common.openDB()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	problems, warnings, err := verifyDB(db, *takeout)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+	for _, p := range problems {
+		fmt.Printf("problem: %s\n", p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %d problems found", common.db, len(problems))
+	}
+	fmt.Printf("%s: OK\n", common.db)
+	return nil
+}
+
+// verifyDB checks a database for corruption and for rows that can't be
+// displayed. Problems indicate a broken database or import; warnings are
+// data the takeout itself was missing, such as attachments.
+func verifyDB(db *sql.DB, takeoutDir string) (problems, warnings []string, err error) {
+	integrity, err := queryStrings(db, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, msg := range integrity {
+		if msg != "ok" {
+			problems = append(problems, "integrity check: "+msg)
+		}
+	}
+
+	rows, err := db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	for rows.Next() {
+		var (
+			table, parent string
+			rowID, fkID   sql.NullInt64
+		)
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan foreign key check: %w", err)
+		}
+		problems = append(problems, fmt.Sprintf("%s row %d references a missing %s", table, rowID.Int64, parent))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating foreign key check: %w", err)
+	}
+
+	checks := []struct {
+		desc    string
+		query   string
+		warning bool
+	}{
+		{"conversations without participants", "SELECT COUNT(*) FROM conversation WHERE id NOT IN (SELECT conversation_id FROM participant)", false},
+		{"conversations without a timestamp", "SELECT COUNT(*) FROM conversation WHERE timestamp_ms IS NULL", false},
+		{"chats without messages", "SELECT COUNT(*) FROM conversation WHERE type = 'chat' AND id NOT IN (SELECT conversation_id FROM message)", false},
+		{"messages without a sender", "SELECT COUNT(*) FROM message WHERE sender_contact_id IS NULL", false},
+		{"images without a media file", "SELECT COUNT(*) FROM image WHERE id NOT IN (SELECT image_id FROM media_file)", true},
+	}
+	for _, c := range checks {
+		var n int
+		if err := db.QueryRow(c.query).Scan(&n); err != nil {
+			return nil, nil, fmt.Errorf("failed to count %s: %w", c.desc, err)
+		}
+		if n == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("%d %s", n, c.desc)
+		if c.warning {
+			warnings = append(warnings, msg)
+		} else {
+			problems = append(problems, msg)
+		}
+	}
+
+	if takeoutDir != "" {
+		missing, err := missingTakeoutFiles(db, takeoutDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, f := range missing {
+			problems = append(problems, "not imported: "+f)
+		}
+	}
+
+	return problems, warnings, nil
+}
+
+// missingTakeoutFiles lists the conversation HTML files in dir that have no
+// conversation in the database.
+func missingTakeoutFiles(db *sql.DB, dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	imported, err := queryStrings(db, "SELECT source_file FROM conversation UNION SELECT source_file FROM duplicate_message")
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(imported))
+	for _, f := range imported {
+		seen[f] = true
+	}
+
+	var missing []string
+	for _, f := range files {
+		name := filepath.Base(f)
+		if name == "Bills.html" || seen[name] {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	return missing, nil
+}
@@ -3,7 +3,9 @@ module github.com/psanford/google-voice-takeout-parser
 go 1.23.1
 
 require (
+	filippo.io/age v1.2.1
 	golang.org/x/net v0.29.0
+	golang.org/x/term v0.24.0
 	modernc.org/sqlite v1.33.1
 )
 
@@ -11,6 +13,7 @@ require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/crypto v0.27.0 // indirect
 	golang.org/x/sys v0.25.0 // indirect
 	modernc.org/libc v1.55.3 // indirect
 	modernc.org/mathutil v1.6.0 // indirect